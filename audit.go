@@ -0,0 +1,346 @@
+package instrumentedsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// AuditRecord describes a data-modifying statement executed through the wrapped driver.
+// It is sent as soon as the statement completes: statements run as part of a transaction are reported before it is
+// committed, and are reported even if it is rolled back.
+type AuditRecord struct {
+	// Verb is the keyword of the first data-modifying statement in the query: INSERT, UPDATE, DELETE, REPLACE or MERGE
+	Verb  string
+	Query string
+	// Args holds the statement arguments after they were passed through the ArgSanitizer, if any
+	Args     []driver.NamedValue
+	Duration time.Duration
+	// RowsAffected is -1 when the driver does not report it, or when the statement was run as a query
+	RowsAffected int64
+	Err          error
+	// User and Tenant are filled in by the AuditIdentityFunc, if any
+	User   string
+	Tenant string
+}
+
+// AuditSink is the interface needed to be implemented to receive audit records, see WithAuditSink
+type AuditSink interface {
+	Audit(ctx context.Context, rec AuditRecord)
+}
+
+// AuditSinkFunc is an adapter which allows a function to be used as an AuditSink.
+type AuditSinkFunc func(ctx context.Context, rec AuditRecord)
+
+// Audit calls f(ctx, rec).
+func (f AuditSinkFunc) Audit(ctx context.Context, rec AuditRecord) {
+	f(ctx, rec)
+}
+
+// AuditIdentityFunc extracts the user and tenant on whose behalf a statement is executed from its context
+type AuditIdentityFunc func(ctx context.Context) (user, tenant string)
+
+// auditVerb returns the verb of query if it has to be audited, or an empty string otherwise
+func (o *opts) auditVerb(query string) string {
+	if o.AuditSink == nil {
+		return ""
+	}
+
+	return dmlVerb(query)
+}
+
+// audit sends a record of query to the audit sink, it is called once the statement has completed
+func (o *opts) audit(ctx context.Context, verb, query string, args interface{}, res driver.Result, err error, since time.Time) {
+	// database/sql retries skipped statements another way, which is audited instead
+	if err == driver.ErrSkip {
+		return
+	}
+
+	rec := AuditRecord{
+		Verb:         verb,
+		Query:        query,
+		Args:         auditArgs(args, o.SanitizeArg),
+		Duration:     time.Since(since),
+		RowsAffected: -1,
+		Err:          err,
+	}
+
+	if err == nil && res != nil {
+		if wrapped, ok := res.(*wrappedResult); ok {
+			res = wrapped.parent
+		}
+		if n, err := res.RowsAffected(); err == nil {
+			rec.RowsAffected = n
+		}
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if o.AuditIdentity != nil {
+		rec.User, rec.Tenant = o.AuditIdentity(ctx)
+	}
+
+	o.AuditSink.Audit(ctx, rec)
+}
+
+// auditArgs copies args, which holds either driver.Value or driver.NamedValue elements, into named values
+func auditArgs(args interface{}, sanitize ArgSanitizer) []driver.NamedValue {
+	argsVal := reflect.ValueOf(args)
+	if argsVal.Kind() != reflect.Slice || argsVal.Len() == 0 {
+		return nil
+	}
+
+	named := make([]driver.NamedValue, 0, argsVal.Len())
+	for i := 0; i < argsVal.Len(); i++ {
+		arg := argsVal.Index(i).Interface()
+		if sanitize != nil {
+			arg = sanitizeArg(arg, i, sanitize)
+		}
+
+		if nv, ok := arg.(driver.NamedValue); ok {
+			named = append(named, nv)
+		} else {
+			named = append(named, driver.NamedValue{Ordinal: i + 1, Value: arg})
+		}
+	}
+
+	return named
+}
+
+// dmlVerb returns the verb of the first data-modifying statement in query: INSERT, UPDATE, DELETE, REPLACE or MERGE,
+// or an empty string if there is none. Every statement of a multi-statement query is looked at, along with the main
+// statement and common table expressions of WITH queries, subqueries, and the statement run by EXPLAIN ANALYZE.
+// Comments, string literals and quoted identifiers are skipped.
+//
+// It is a heuristic rather than a parser. It misses data modified by statements that do not start with one of these
+// verbs, such as CALL, EXEC, SELECT INTO, COPY, TRUNCATE and other DDL, as well as by functions with side effects.
+func dmlVerb(query string) string {
+	// MySQL escapes quotes with backslashes and starts comments with #, which standard SQL doesn't, and reading a query
+	// the wrong way may hide a statement. Both ways are tried, a statement found by either is reported.
+	if verb := scanDMLVerb(sqlScanner{query: query}); verb != "" {
+		return verb
+	}
+
+	return scanDMLVerb(sqlScanner{query: query, mysql: true})
+}
+
+// scanDMLVerb is dmlVerb for a single way of reading the query s scans
+func scanDMLVerb(s sqlScanner) string {
+	// One frame per level of parentheses, the statement at each level is looked at independently
+	frames := []stmtFrame{{start: true}}
+
+	for {
+		tok, word := s.next()
+		top := &frames[len(frames)-1]
+
+		switch tok {
+		case tokEOF:
+			return ""
+		case tokSemicolon:
+			frames = append(frames[:0], stmtFrame{start: true})
+		case tokOpen:
+			frames = append(frames, stmtFrame{start: true})
+		case tokClose:
+			if len(frames) > 1 {
+				frames = frames[:len(frames)-1]
+				top = &frames[len(frames)-1]
+			}
+			// The main statement of a WITH query and the statement explained follow a closing parenthesis
+			if top.kind != stmtPlain {
+				top.start = true
+			}
+		case tokComma, tokOther:
+			top.start = false
+		case tokWord:
+			if !top.start {
+				continue
+			}
+
+			verb := strings.ToUpper(word)
+			switch {
+			case verb == "WITH":
+				top.kind, top.start = stmtWith, false
+			case verb == "EXPLAIN":
+				top.kind = stmtExplain
+			case verb == "ANALYZE" || verb == "ANALYSE":
+				// Options of EXPLAIN may be parenthesized
+				if top.kind == stmtExplain {
+					top.analyze = true
+				} else if len(frames) > 1 && frames[len(frames)-2].kind == stmtExplain {
+					frames[len(frames)-2].analyze = true
+				}
+			case isDMLVerb(verb) && !s.peekOpen():
+				// Followed by a parenthesis, the verb would be a function such as REPLACE(s, old, new) instead.
+				// EXPLAIN only runs the statement it explains with ANALYZE
+				if top.kind != stmtExplain || top.analyze {
+					return verb
+				}
+				top.kind, top.start = stmtPlain, false
+			case verb == "SELECT" || verb == "VALUES" || verb == "TABLE":
+				top.kind, top.start = stmtPlain, false
+			case top.kind == stmtPlain || top.kind == stmtWith:
+				top.start = false
+			}
+		}
+	}
+}
+
+func isDMLVerb(verb string) bool {
+	switch verb {
+	case "INSERT", "UPDATE", "DELETE", "REPLACE", "MERGE":
+		return true
+	}
+	return false
+}
+
+type stmtKind int
+
+const (
+	stmtPlain stmtKind = iota
+	stmtWith
+	stmtExplain
+)
+
+// stmtFrame is the state of dmlVerb for the statement at one level of parentheses
+type stmtFrame struct {
+	kind stmtKind
+	// start is set when the next word may be the verb of a statement
+	start bool
+	// analyze is set once the ANALYZE option of EXPLAIN was seen
+	analyze bool
+}
+
+type sqlToken int
+
+const (
+	tokEOF sqlToken = iota
+	tokWord
+	tokOpen
+	tokClose
+	tokComma
+	tokSemicolon
+	tokOther
+)
+
+// sqlScanner splits a query into the tokens dmlVerb cares about, skipping whitespace, comments, string literals,
+// quoted identifiers and PostgreSQL dollar-quoted strings
+type sqlScanner struct {
+	query string
+	pos   int
+	// mysql is set to read # as the start of a comment and backslashes as escapes in quoted strings
+	mysql bool
+}
+
+func (s *sqlScanner) next() (sqlToken, string) {
+	for s.pos < len(s.query) {
+		rest := s.query[s.pos:]
+		r, size := utf8.DecodeRuneInString(rest)
+
+		switch {
+		case unicode.IsSpace(r):
+			s.pos += size
+		case strings.HasPrefix(rest, "--"):
+			s.skipPast("\n", 2)
+		case strings.HasPrefix(rest, "/*"):
+			s.skipPast("*/", 2)
+		case r == '#' && s.mysql:
+			s.skipPast("\n", 1)
+		case r == '\'' || r == '"' || r == '`':
+			s.skipQuoted(byte(r))
+			return tokOther, ""
+		case r == '[':
+			s.skipPast("]", 1)
+			return tokOther, ""
+		case r == '$':
+			if tag := dollarQuoteTag(rest); tag != "" {
+				s.skipPast(tag, len(tag))
+			} else {
+				s.pos++
+			}
+			return tokOther, ""
+		case r == '(':
+			s.pos++
+			return tokOpen, ""
+		case r == ')':
+			s.pos++
+			return tokClose, ""
+		case r == ',':
+			s.pos++
+			return tokComma, ""
+		case r == ';':
+			s.pos++
+			return tokSemicolon, ""
+		case isWordRune(r):
+			end := strings.IndexFunc(rest, func(r rune) bool { return !isWordRune(r) })
+			if end < 0 {
+				end = len(rest)
+			}
+			s.pos += end
+			return tokWord, rest[:end]
+		default:
+			s.pos += size
+			return tokOther, ""
+		}
+	}
+
+	return tokEOF, ""
+}
+
+// skipPast moves past the next occurrence of end found after the first skip bytes, or to the end of the query
+func (s *sqlScanner) skipPast(end string, skip int) {
+	i := strings.Index(s.query[s.pos+skip:], end)
+	if i < 0 {
+		s.pos = len(s.query)
+		return
+	}
+	s.pos += skip + i + len(end)
+}
+
+// skipQuoted moves past the string or identifier starting at the current position and quoted with quote.
+// Doubled quotes escape themselves, which skipping from one quoted part to the next handles.
+func (s *sqlScanner) skipQuoted(quote byte) {
+	if !s.mysql || quote == '`' {
+		s.skipPast(string(quote), 1)
+		return
+	}
+
+	for s.pos++; s.pos < len(s.query); s.pos++ {
+		switch s.query[s.pos] {
+		case '\\':
+			s.pos++
+		case quote:
+			s.pos++
+			return
+		}
+	}
+	s.pos = len(s.query)
+}
+
+// peekOpen reports whether the next token is an opening parenthesis
+func (s *sqlScanner) peekOpen() bool {
+	rest := strings.TrimLeftFunc(s.query[s.pos:], unicode.IsSpace)
+	return strings.HasPrefix(rest, "(")
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// dollarQuoteTag returns the tag opening the dollar-quoted string s starts with, such as $$ or $body$,
+// or an empty string if s starts with something else, such as a $1 placeholder
+func dollarQuoteTag(s string) string {
+	end := strings.IndexFunc(s[1:], func(r rune) bool { return !isWordRune(r) })
+	if end < 0 || s[1+end] != '$' {
+		return ""
+	}
+	if tag := s[1 : 1+end]; tag != "" && unicode.IsDigit(rune(tag[0])) {
+		return ""
+	}
+
+	return s[:end+2]
+}
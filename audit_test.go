@@ -0,0 +1,129 @@
+package instrumentedsql_test
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+
+	"github.com/luna-duclos/instrumentedsql"
+)
+
+type userKey struct{}
+
+func TestAuditSink(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		records []instrumentedsql.AuditRecord
+	)
+	sink := instrumentedsql.AuditSinkFunc(func(ctx context.Context, rec instrumentedsql.AuditRecord) {
+		mu.Lock()
+		defer mu.Unlock()
+		records = append(records, rec)
+	})
+	identity := func(ctx context.Context) (string, string) {
+		user, _ := ctx.Value(userKey{}).(string)
+		return user, "acme"
+	}
+	redactPassword := func(name string, ordinal int, value interface{}) interface{} {
+		if name == "password" {
+			return "<redacted>"
+		}
+		return value
+	}
+
+	d := instrumentedsql.WrapDriver(&fakeDriver{},
+		instrumentedsql.WithAuditSink(sink),
+		instrumentedsql.WithAuditIdentity(identity),
+		instrumentedsql.WithArgSanitizer(redactPassword),
+		instrumentedsql.WithOpsExcluded(instrumentedsql.OpSQLConnExec, instrumentedsql.OpSQLStmtExec),
+	)
+	db := openDB(t, d)
+	defer db.Close()
+
+	ctx := context.WithValue(context.Background(), userKey{}, "alice")
+
+	tests := []struct {
+		query    string
+		args     []interface{}
+		wantVerb string
+	}{
+		{query: "SELECT 1"},
+		{query: "  insert INTO users VALUES (?)", args: []interface{}{"bob"}, wantVerb: "INSERT"},
+		{query: "/* app */ -- update\nUPDATE users SET password = @password", args: []interface{}{sql.Named("password", "hunter2")}, wantVerb: "UPDATE"},
+		{query: "(DELETE FROM users)", wantVerb: "DELETE"},
+		{query: "updated_at"},
+		{query: "SELECT * FROM users FOR UPDATE"},
+		{query: "SELECT replace(name, 'a', 'b') FROM users"},
+		{query: "SELECT 'DELETE FROM users; DELETE FROM users' AS \"update\""},
+		{query: "SELECT $body$; DELETE FROM users$body$"},
+		{query: "SELECT 1; delete FROM users", wantVerb: "DELETE"},
+		{query: "# note\nDELETE FROM users", wantVerb: "DELETE"},
+		{query: "SELECT 'a\\'b'; DELETE FROM users", wantVerb: "DELETE"},
+		{query: "SELECT 'C:\\'; DELETE FROM users", wantVerb: "DELETE"},
+		{query: "WITH recent (id) AS (SELECT id FROM users) DELETE FROM users USING recent", wantVerb: "DELETE"},
+		{query: "WITH gone AS (DELETE FROM users RETURNING id) SELECT count(*) FROM gone", wantVerb: "DELETE"},
+		{query: "WITH a AS (SELECT 1), b AS (SELECT 2) SELECT * FROM a, b"},
+		{query: "EXPLAIN DELETE FROM users"},
+		{query: "EXPLAIN ANALYZE VERBOSE DELETE FROM users", wantVerb: "DELETE"},
+		{query: "EXPLAIN (ANALYZE, BUFFERS) UPDATE users SET name = ?", args: []interface{}{"bob"}, wantVerb: "UPDATE"},
+		{query: "REPLACE INTO users VALUES (?)", args: []interface{}{"bob"}, wantVerb: "REPLACE"},
+		{query: "MERGE INTO users USING staged ON users.id = staged.id WHEN MATCHED THEN DELETE", wantVerb: "MERGE"},
+	}
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			records = nil
+
+			if _, err := db.ExecContext(ctx, test.query, test.args...); err != nil {
+				t.Fatal(err)
+			}
+
+			if test.wantVerb == "" {
+				if len(records) != 0 {
+					t.Errorf("expected no audit record, got %+v", records)
+				}
+				return
+			}
+
+			if len(records) != 1 {
+				t.Fatalf("expected 1 audit record, got %d", len(records))
+			}
+			rec := records[0]
+			if rec.Verb != test.wantVerb || rec.Query != test.query {
+				t.Errorf("expected %s %q, got %s %q", test.wantVerb, test.query, rec.Verb, rec.Query)
+			}
+			if rec.RowsAffected != 1 {
+				t.Errorf("expected 1 row affected, got %d", rec.RowsAffected)
+			}
+			if rec.User != "alice" || rec.Tenant != "acme" {
+				t.Errorf("expected alice of acme, got %q of %q", rec.User, rec.Tenant)
+			}
+			if len(rec.Args) != len(test.args) {
+				t.Fatalf("expected %d args, got %+v", len(test.args), rec.Args)
+			}
+			for _, arg := range rec.Args {
+				if arg.Value == "hunter2" {
+					t.Errorf("expected password to be redacted, got %+v", rec.Args)
+				}
+			}
+		})
+	}
+
+	t.Run("prepared statement", func(t *testing.T) {
+		records = nil
+
+		stmt, err := db.PrepareContext(ctx, "DELETE FROM users WHERE id = ?")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+
+		if _, err := stmt.ExecContext(ctx, 1); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(records) != 1 || records[0].Verb != "DELETE" || records[0].Args[0].Value != int64(1) {
+			t.Errorf("expected the statement to be audited, got %+v", records)
+		}
+	})
+}
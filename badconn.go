@@ -0,0 +1,141 @@
+package instrumentedsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// maxBadConnAttempts is how many times in a row database/sql runs an operation that fails with driver.ErrBadConn
+	// before giving up: twice on any connection, then once on a new one
+	maxBadConnAttempts = 3
+	// maxPendingBadConns bounds the failures remembered while waiting for their retry,
+	// in case database/sql never retries them, e.g. because their context got cancelled in between
+	maxPendingBadConns = 1024
+)
+
+// badConnTracker counts operations that failed with driver.ErrBadConn, and remembers them so that the attempt
+// database/sql retries them with can be told apart from a first attempt. It is shared by everything a driver wraps.
+//
+// A retry is recognized by running the same op for the same query with the same context, this is a best effort:
+// unrelated operations sharing all three, such as the same query run concurrently with context.Background(),
+// may be mistaken for a retry.
+type badConnTracker struct {
+	count uint64
+	// pending mirrors len(failed), it lets operations skip the lock when no failure awaits a retry
+	pending int32
+
+	mu     sync.Mutex
+	failed map[badConnKey]int
+}
+
+type badConnKey struct {
+	ctx   context.Context
+	op    string
+	query string
+}
+
+// newBadConnKey returns the key of op, or false if its context cannot be used as a map key.
+// The root span started for op by WithNewRootSpanIfMissing differs between attempts, so the context of the caller
+// is used instead.
+func newBadConnKey(ctx context.Context, op, query string) (badConnKey, bool) {
+	if rc, ok := ctx.(*rootSpanContext); ok {
+		ctx = rc.Context
+	}
+	if ctx == nil {
+		return badConnKey{}, false
+	}
+
+	// Contexts are usually pointers, which can always be hashed. A comparable struct may still hold an unhashable
+	// value in an interface field and make the map panic, so only those without any field, such as
+	// context.Background(), are used as well.
+	if t := reflect.TypeOf(ctx); t.Kind() != reflect.Ptr && (t.Size() != 0 || !t.Comparable()) {
+		return badConnKey{}, false
+	}
+
+	return badConnKey{ctx: ctx, op: op, query: query}, true
+}
+
+// retry reports whether op previously failed with driver.ErrBadConn for the same query and context
+func (t *badConnTracker) retry(ctx context.Context, op, query string) bool {
+	if t == nil || atomic.LoadInt32(&t.pending) == 0 {
+		return false
+	}
+	key, ok := newBadConnKey(ctx, op, query)
+	if !ok {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, ok = t.failed[key]
+	return ok
+}
+
+// done records the outcome of op, a failure with driver.ErrBadConn is counted and remembered until it is retried
+func (t *badConnTracker) done(ctx context.Context, op, query string, err error) {
+	if t == nil {
+		return
+	}
+
+	bad := err == driver.ErrBadConn
+	if bad {
+		atomic.AddUint64(&t.count, 1)
+	}
+	if !bad && atomic.LoadInt32(&t.pending) == 0 {
+		return
+	}
+	key, ok := newBadConnKey(ctx, op, query)
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	attempts := t.failed[key] + 1
+	switch {
+	case !bad, attempts >= maxBadConnAttempts:
+		delete(t.failed, key)
+	case t.failed == nil, len(t.failed) >= maxPendingBadConns:
+		t.failed = map[badConnKey]int{key: attempts}
+	default:
+		t.failed[key] = attempts
+	}
+	atomic.StoreInt32(&t.pending, int32(len(t.failed)))
+}
+
+// badConnCount returns the number of operations that failed with driver.ErrBadConn
+func (t *badConnTracker) badConnCount() uint64 {
+	if t == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&t.count)
+}
+
+// setRetryLabel labels span as the retry of an attempt at op that failed with driver.ErrBadConn
+func (o *opts) setRetryLabel(ctx context.Context, op, query string, span Span) {
+	if o.hasOpExcluded(opBadConn) {
+		return
+	}
+
+	if o.badConns.retry(ctx, op, query) {
+		span.SetLabel("bad_conn_retry", "true")
+	}
+}
+
+// reportBadConn records the outcome of op, logging it if it failed with driver.ErrBadConn,
+// which database/sql answers by retrying on another connection.
+// It is called whether op is excluded or not, only excluding OpSQLBadConn turns it off.
+func (o *opts) reportBadConn(ctx context.Context, op, query string, err error) {
+	o.badConns.done(ctx, op, query, err)
+
+	if err == driver.ErrBadConn {
+		o.Log(ctx, OpSQLBadConn, "op", op)
+	}
+}
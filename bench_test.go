@@ -0,0 +1,134 @@
+package instrumentedsql_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/luna-duclos/instrumentedsql"
+)
+
+var allOps = []string{
+	instrumentedsql.OpSQLPrepare,
+	instrumentedsql.OpSQLConnExec,
+	instrumentedsql.OpSQLConnQuery,
+	instrumentedsql.OpSQLStmtExec,
+	instrumentedsql.OpSQLStmtQuery,
+	instrumentedsql.OpSQLStmtClose,
+	instrumentedsql.OpSQLTxBegin,
+	instrumentedsql.OpSQLTxCommit,
+	instrumentedsql.OpSQLTxRollback,
+	instrumentedsql.OpSQLResLastInsertID,
+	instrumentedsql.OpSQLResRowsAffected,
+	instrumentedsql.OpSQLRowsNext,
+	instrumentedsql.OpSQLPing,
+	instrumentedsql.OpSQLDummyPing,
+	instrumentedsql.OpSQLConnectorConnect,
+	instrumentedsql.OpSQLBadConn,
+}
+
+// benchDrivers returns the raw fake driver along with wrapped drivers with instrumentation disabled, disabled for
+// everything but ping, which keeps connections wrapped while leaving the benchmarked ops disabled, and enabled
+func benchDrivers() []struct {
+	name string
+	d    driver.Driver
+} {
+	var allButPing []string
+	for _, op := range allOps {
+		if op != instrumentedsql.OpSQLPing {
+			allButPing = append(allButPing, op)
+		}
+	}
+
+	return []struct {
+		name string
+		d    driver.Driver
+	}{
+		{name: "raw", d: &fakeDriver{}},
+		{name: "disabled", d: instrumentedsql.WrapDriver(&fakeDriver{}, instrumentedsql.WithOpsExcluded(allOps...))},
+		{name: "ping-only", d: instrumentedsql.WrapDriver(&fakeDriver{}, instrumentedsql.WithOpsExcluded(allButPing...))},
+		{name: "enabled", d: instrumentedsql.WrapDriver(&fakeDriver{})},
+	}
+}
+
+func benchConn(b *testing.B, d driver.Driver, f func(ctx context.Context, conn driver.Conn) error) {
+	conn, err := d.Open("")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := f(ctx, conn); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkExec(b *testing.B) {
+	args := []driver.NamedValue{{Ordinal: 1, Value: int64(1)}}
+	for _, bd := range benchDrivers() {
+		b.Run(bd.name, func(b *testing.B) {
+			benchConn(b, bd.d, func(ctx context.Context, conn driver.Conn) error {
+				res, err := conn.(driver.ExecerContext).ExecContext(ctx, "UPDATE t SET a = ?", args)
+				if err != nil {
+					return err
+				}
+				_, err = res.RowsAffected()
+				return err
+			})
+		})
+	}
+}
+
+func BenchmarkQuery(b *testing.B) {
+	dest := make([]driver.Value, 1)
+	for _, bd := range benchDrivers() {
+		b.Run(bd.name, func(b *testing.B) {
+			benchConn(b, bd.d, func(ctx context.Context, conn driver.Conn) error {
+				rows, err := conn.(driver.QueryerContext).QueryContext(ctx, "SELECT 1", nil)
+				if err != nil {
+					return err
+				}
+				for rows.Next(dest) == nil {
+				}
+				return rows.Close()
+			})
+		})
+	}
+}
+
+func BenchmarkPreparedExec(b *testing.B) {
+	args := []driver.NamedValue{{Ordinal: 1, Value: int64(1)}}
+	for _, bd := range benchDrivers() {
+		b.Run(bd.name, func(b *testing.B) {
+			benchConn(b, bd.d, func(ctx context.Context, conn driver.Conn) error {
+				stmt, err := conn.(driver.ConnPrepareContext).PrepareContext(ctx, "UPDATE t SET a = ?")
+				if err != nil {
+					return err
+				}
+				if _, err := stmt.(driver.StmtExecContext).ExecContext(ctx, args); err != nil {
+					return err
+				}
+				return stmt.Close()
+			})
+		})
+	}
+}
+
+func BenchmarkTx(b *testing.B) {
+	for _, bd := range benchDrivers() {
+		b.Run(bd.name, func(b *testing.B) {
+			benchConn(b, bd.d, func(ctx context.Context, conn driver.Conn) error {
+				tx, err := conn.(driver.ConnBeginTx).BeginTx(ctx, driver.TxOptions{})
+				if err != nil {
+					return err
+				}
+				return tx.Commit()
+			})
+		})
+	}
+}
@@ -6,9 +6,14 @@ import (
 	"time"
 )
 
+// WrappedConn is the connection returned by a wrapped driver when there is something to instrument or audit about it.
+// Unlike the other wrappers it keeps value receivers: it is exported and callers may assert conn.(WrappedConn), which
+// pointer receivers would break, while copying its few words per call is cheap and the state shared between calls
+// lives behind pointers.
 type WrappedConn struct {
-	opts
+	*opts
 	Parent driver.Conn
+	txRoot *txRoot
 }
 
 // Compile time validation that our types implement the expected interfaces
@@ -23,13 +28,23 @@ var (
 	_ driver.QueryerContext     = WrappedConn{}
 )
 
+// wrapConn wraps conn, unless there is nothing to instrument or audit about it or anything it returns.
+// When all ops are excluded the parent connection is used as is, which makes the wrapped driver as cheap as the parent one.
+func (o *opts) wrapConn(conn driver.Conn) driver.Conn {
+	if o.hasOpExcluded(opConn) && o.AuditSink == nil {
+		return conn
+	}
+
+	return WrappedConn{opts: o, Parent: conn, txRoot: &txRoot{}}
+}
+
 func (c WrappedConn) Prepare(query string) (driver.Stmt, error) {
 	parent, err := c.Parent.Prepare(query)
 	if err != nil {
 		return nil, err
 	}
 
-	return wrappedStmt{opts: c.opts, query: query, parent: parent}, nil
+	return c.wrapStmt(nil, query, parent, c.txRoot), nil
 }
 
 func (c WrappedConn) Close() error {
@@ -42,18 +57,30 @@ func (c WrappedConn) Begin() (driver.Tx, error) {
 		return nil, err
 	}
 
-	return wrappedTx{opts: c.opts, parent: tx}, nil
+	return c.wrapTx(nil, tx, nil), nil
 }
 
 func (c WrappedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx driver.Tx, err error) {
-	if !c.hasOpExcluded(OpSQLTxBegin) {
-		span := c.GetSpan(ctx).NewChild(OpSQLTxBegin)
+	if !c.hasOpExcluded(opTxBegin) {
+		var span Span
+		span, ctx = c.startSpan(ctx, nil, OpSQLTxBegin)
 		span.SetLabel("component", "database/sql")
+		setDeadlineLabel(ctx, span)
+		c.setRetryLabel(ctx, OpSQLTxBegin, "", span)
 		start := time.Now()
 		defer func() {
-			span.SetError(err)
-			span.Finish()
-			c.Log(ctx, OpSQLTxBegin, "err", err, "duration", time.Since(start))
+			setSpanError(ctx, span, err)
+			// The root span started for the transaction ends with it
+			if err != nil || !startedRoot(ctx) {
+				span.Finish()
+			}
+			logOp(ctx, c.opts, OpSQLTxBegin, err, start)
+		}()
+	}
+
+	if !c.hasOpExcluded(opBadConn) {
+		defer func() {
+			c.reportBadConn(ctx, OpSQLTxBegin, "", err)
 		}()
 	}
 
@@ -63,7 +90,8 @@ func (c WrappedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx dri
 			return nil, err
 		}
 
-		return wrappedTx{opts: c.opts, ctx: ctx, parent: tx}, nil
+		c.txRoot.set(rootSpan(ctx))
+		return c.wrapTx(ctx, tx, c.txRoot), nil
 	}
 
 	tx, err = c.Parent.Begin()
@@ -71,28 +99,38 @@ func (c WrappedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx dri
 		return nil, err
 	}
 
-	return wrappedTx{opts: c.opts, ctx: ctx, parent: tx}, nil
+	c.txRoot.set(rootSpan(ctx))
+	return c.wrapTx(ctx, tx, c.txRoot), nil
 }
 
 func (c WrappedConn) PrepareContext(ctx context.Context, query string) (stmt driver.Stmt, err error) {
-	if !c.hasOpExcluded(OpSQLPrepare) {
-		span := c.GetSpan(ctx).NewChild(OpSQLPrepare)
+	if !c.hasOpExcluded(opPrepare) {
+		var span Span
+		span, ctx = c.startSpan(ctx, c.txRoot.get(), OpSQLPrepare)
 		span.SetLabel("component", "database/sql")
+		setDeadlineLabel(ctx, span)
+		c.setRetryLabel(ctx, OpSQLPrepare, query, span)
 		start := time.Now()
 		defer func() {
-			span.SetError(err)
+			setSpanError(ctx, span, err)
 			span.Finish()
 			logQuery(ctx, c.opts, OpSQLPrepare, query, err, nil, start)
 		}()
 	}
 
+	if !c.hasOpExcluded(opBadConn) {
+		defer func() {
+			c.reportBadConn(ctx, OpSQLPrepare, query, err)
+		}()
+	}
+
 	if connPrepareCtx, ok := c.Parent.(driver.ConnPrepareContext); ok {
 		stmt, err := connPrepareCtx.PrepareContext(ctx, query)
 		if err != nil {
 			return nil, err
 		}
 
-		return wrappedStmt{opts: c.opts, ctx: ctx, query: query, parent: stmt}, nil
+		return c.wrapStmt(callerContext(ctx), query, stmt, c.txRoot), nil
 	}
 
 	return c.Prepare(query)
@@ -105,36 +143,52 @@ func (c WrappedConn) Exec(query string, args []driver.Value) (driver.Result, err
 			return nil, err
 		}
 
-		return wrappedResult{opts: c.opts, parent: res}, nil
+		return c.wrapResult(nil, res), nil
 	}
 
 	return nil, driver.ErrSkip
 }
 
 func (c WrappedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (r driver.Result, err error) {
-	if !c.hasOpExcluded(OpSQLConnExec) {
-		span := c.GetSpan(ctx).NewChild(OpSQLConnExec)
+	if !c.hasOpExcluded(opConnExec) {
+		var span Span
+		span, ctx = c.startSpan(ctx, c.txRoot.get(), OpSQLConnExec)
 		span.SetLabel("component", "database/sql")
+		setDeadlineLabel(ctx, span)
+		c.setRetryLabel(ctx, OpSQLConnExec, query, span)
 		span.SetLabel("query", query)
 		if !c.OmitArgs {
-			span.SetLabel("args", formatArgs(args))
+			span.SetLabel("args", formatArgs(args, c.SanitizeArg))
 		}
 		start := time.Now()
 		defer func() {
-			span.SetError(err)
+			setSpanError(ctx, span, err)
 			span.Finish()
 
 			logQuery(ctx, c.opts, OpSQLConnExec, query, err, args, start)
 		}()
 	}
 
+	if !c.hasOpExcluded(opBadConn) {
+		defer func() {
+			c.reportBadConn(ctx, OpSQLConnExec, query, err)
+		}()
+	}
+
+	if verb := c.auditVerb(query); verb != "" {
+		start := time.Now()
+		defer func() {
+			c.audit(ctx, verb, query, args, r, err, start)
+		}()
+	}
+
 	if execContext, ok := c.Parent.(driver.ExecerContext); ok {
 		res, err := execContext.ExecContext(ctx, query, args)
 		if err != nil {
 			return nil, err
 		}
 
-		return wrappedResult{opts: c.opts, ctx: ctx, parent: res}, nil
+		return c.wrapResult(callerContext(ctx), res), nil
 	}
 
 	// Fallback implementation
@@ -153,14 +207,22 @@ func (c WrappedConn) ExecContext(ctx context.Context, query string, args []drive
 
 func (c WrappedConn) Ping(ctx context.Context) (err error) {
 	if pinger, ok := c.Parent.(driver.Pinger); ok {
-		if !c.hasOpExcluded(OpSQLPing) {
-			span := c.GetSpan(ctx).NewChild(OpSQLPing)
+		if !c.hasOpExcluded(opPing) {
+			span, _ := c.startSpan(ctx, c.txRoot.get(), OpSQLPing)
 			span.SetLabel("component", "database/sql")
+			setDeadlineLabel(ctx, span)
+			c.setRetryLabel(ctx, OpSQLPing, "", span)
 			start := time.Now()
 			defer func() {
-				span.SetError(err)
+				setSpanError(ctx, span, err)
 				span.Finish()
-				c.Log(ctx, OpSQLPing, "err", err, "duration", time.Since(start))
+				logOp(ctx, c.opts, OpSQLPing, err, start)
+			}()
+		}
+
+		if !c.hasOpExcluded(opBadConn) {
+			defer func() {
+				c.reportBadConn(ctx, OpSQLPing, "", err)
 			}()
 		}
 
@@ -179,7 +241,7 @@ func (c WrappedConn) Query(query string, args []driver.Value) (driver.Rows, erro
 			return nil, err
 		}
 
-		return wrappedRows{opts: c.opts, parent: rows}, nil
+		return c.wrapRows(nil, rows), nil
 	}
 
 	return nil, driver.ErrSkip
@@ -193,28 +255,47 @@ func (c WrappedConn) QueryContext(ctx context.Context, query string, args []driv
 		return nil, driver.ErrSkip
 	}
 
-	if !c.hasOpExcluded(OpSQLConnQuery) {
-		span := c.GetSpan(ctx).NewChild(OpSQLConnQuery)
+	if !c.hasOpExcluded(opConnQuery) {
+		var span Span
+		span, ctx = c.startSpan(ctx, c.txRoot.get(), OpSQLConnQuery)
 		span.SetLabel("component", "database/sql")
+		setDeadlineLabel(ctx, span)
+		c.setRetryLabel(ctx, OpSQLConnQuery, query, span)
 		span.SetLabel("query", query)
 		if !c.OmitArgs {
-			span.SetLabel("args", formatArgs(args))
+			span.SetLabel("args", formatArgs(args, c.SanitizeArg))
 		}
 		start := time.Now()
 		defer func() {
-			span.SetError(err)
-			span.Finish()
+			setSpanError(ctx, span, err)
+			// The root span started for the query ends with its rows
+			if err != nil || !startedRoot(ctx) {
+				span.Finish()
+			}
 			logQuery(ctx, c.opts, OpSQLConnQuery, query, err, args, start)
 		}()
 	}
 
+	if !c.hasOpExcluded(opBadConn) {
+		defer func() {
+			c.reportBadConn(ctx, OpSQLConnQuery, query, err)
+		}()
+	}
+
+	if verb := c.auditVerb(query); verb != "" {
+		start := time.Now()
+		defer func() {
+			c.audit(ctx, verb, query, args, nil, err, start)
+		}()
+	}
+
 	if queryerContext, ok := c.Parent.(driver.QueryerContext); ok {
 		rows, err := queryerContext.QueryContext(ctx, query, args)
 		if err != nil {
 			return nil, err
 		}
 
-		return wrappedRows{opts: c.opts, ctx: ctx, parent: rows}, nil
+		return c.wrapRows(ctx, rows), nil
 	}
 
 	dargs, err := namedValueToValue(args)
@@ -228,5 +309,10 @@ func (c WrappedConn) QueryContext(ctx context.Context, query string, args []driv
 		return nil, ctx.Err()
 	}
 
-	return c.Query(query, dargs)
+	rows, err = c.Parent.(driver.Queryer).Query(query, dargs)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.wrapRows(ctx, rows), nil
 }
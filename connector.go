@@ -9,7 +9,7 @@ import (
 )
 
 type wrappedConnector struct {
-	opts
+	*opts
 	parent    driver.Connector
 	driverRef *WrappedDriver
 }
@@ -19,14 +19,22 @@ var (
 )
 
 func (c wrappedConnector) Connect(ctx context.Context) (conn driver.Conn, err error) {
-	if !c.hasOpExcluded(OpSQLConnectorConnect) {
-		span := c.GetSpan(ctx).NewChild(OpSQLConnectorConnect)
+	if !c.hasOpExcluded(opConnectorConnect) {
+		span, _ := c.startSpan(ctx, nil, OpSQLConnectorConnect)
 		span.SetLabel("component", "database/sql")
+		setDeadlineLabel(ctx, span)
+		c.setRetryLabel(ctx, OpSQLConnectorConnect, "", span)
 		start := time.Now()
 		defer func() {
-			span.SetError(err)
+			setSpanError(ctx, span, err)
 			span.Finish()
-			c.Log(ctx, OpSQLConnectorConnect, "err", err, "duration", time.Since(start))
+			logOp(ctx, c.opts, OpSQLConnectorConnect, err, start)
+		}()
+	}
+
+	if !c.hasOpExcluded(opBadConn) {
+		defer func() {
+			c.reportBadConn(ctx, OpSQLConnectorConnect, "", err)
 		}()
 	}
 
@@ -35,7 +43,7 @@ func (c wrappedConnector) Connect(ctx context.Context) (conn driver.Conn, err er
 		return nil, err
 	}
 
-	return WrappedConn{opts: c.driverRef.opts, Parent: conn}, nil
+	return c.driverRef.wrapConn(conn), nil
 }
 
 func (c wrappedConnector) Driver() driver.Driver {
@@ -5,7 +5,7 @@ import "database/sql/driver"
 // WrappedDriver wraps a driver and adds instrumentation.
 // Use WrapDriver to create a new WrappedDriver.
 type WrappedDriver struct {
-	opts
+	*opts
 	parent driver.Driver
 }
 
@@ -21,10 +21,10 @@ var (
 // Any call without a context passed will not be instrumented. Please be sure to use the ___Context() and BeginTx() function calls added in Go 1.8
 // instead of the older calls which do not accept a context.
 func WrapDriver(driver driver.Driver, opts ...Opt) WrappedDriver {
-	d := WrappedDriver{parent: driver}
+	d := WrappedDriver{opts: newOpts(), parent: driver}
 
 	for _, opt := range opts {
-		opt(&d.opts)
+		opt(d.opts)
 	}
 
 	if d.Logger == nil {
@@ -37,6 +37,17 @@ func WrapDriver(driver driver.Driver, opts ...Opt) WrappedDriver {
 	return d
 }
 
+// BadConnCount returns the number of operations that failed with driver.ErrBadConn, whether they are excluded or not.
+// database/sql silently retries those on another connection, so a growing count indicates connection churn.
+// It stays at 0 if OpSQLBadConn is excluded.
+func (d WrappedDriver) BadConnCount() uint64 {
+	if d.opts == nil {
+		return 0
+	}
+
+	return d.badConns.badConnCount()
+}
+
 // Open implements the database/sql/driver.Driver interface for WrappedDriver.
 func (d WrappedDriver) Open(name string) (driver.Conn, error) {
 	conn, err := d.parent.Open(name)
@@ -44,5 +55,5 @@ func (d WrappedDriver) Open(name string) (driver.Conn, error) {
 		return nil, err
 	}
 
-	return WrappedConn{opts: d.opts, Parent: conn}, nil
+	return d.wrapConn(conn), nil
 }
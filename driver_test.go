@@ -0,0 +1,596 @@
+package instrumentedsql_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luna-duclos/instrumentedsql"
+	"github.com/luna-duclos/instrumentedsql/instrumentedsqltest"
+)
+
+func TestBadConnIsReported(t *testing.T) {
+	tracer := instrumentedsqltest.NewRecordingTracer()
+	logger := instrumentedsqltest.NewRecordingLogger()
+	fake := &fakeDriver{badConns: 1}
+	d := instrumentedsql.WrapDriver(fake, instrumentedsql.WithTracer(tracer), instrumentedsql.WithLogger(logger))
+
+	db := openDB(t, d)
+	defer db.Close()
+
+	if _, err := db.ExecContext(context.Background(), "UPDATE t SET a = 1"); err != nil {
+		t.Fatalf("expected database/sql to retry past the bad connection, got %v", err)
+	}
+
+	spans := tracer.SpansNamed(instrumentedsql.OpSQLConnExec)
+	if len(spans) != 2 {
+		t.Fatalf("expected a span for the failed attempt and one for the retry, got %d", len(spans))
+	}
+	if v, _ := spans[0].Label("bad_conn"); v != "true" {
+		t.Errorf("expected first attempt to be labelled bad_conn, got labels %v", spans[0].Labels)
+	}
+	if _, ok := spans[0].Label("bad_conn_retry"); ok {
+		t.Errorf("expected first attempt not to be labelled bad_conn_retry, got labels %v", spans[0].Labels)
+	}
+	if _, ok := spans[1].Label("bad_conn"); ok {
+		t.Errorf("expected retry not to be labelled bad_conn, got labels %v", spans[1].Labels)
+	}
+	if v, _ := spans[1].Label("bad_conn_retry"); v != "true" {
+		t.Errorf("expected retry to be labelled bad_conn_retry, got labels %v", spans[1].Labels)
+	}
+
+	logger.AssertLoggedValue(t, instrumentedsql.OpSQLBadConn, "op", instrumentedsql.OpSQLConnExec)
+
+	if n := d.BadConnCount(); n != 1 {
+		t.Errorf("expected bad conn count of 1, got %d", n)
+	}
+
+	// The next query with the same context is not a retry
+	tracer.Reset()
+	if _, err := db.ExecContext(context.Background(), "UPDATE t SET a = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tracer.AssertSpan(t, instrumentedsql.OpSQLConnExec).Label("bad_conn_retry"); ok {
+		t.Error("expected a new query not to be labelled bad_conn_retry")
+	}
+}
+
+func TestBadConnIsCountedWhenExcluded(t *testing.T) {
+	tests := []struct {
+		name     string
+		excluded []string
+		want     uint64
+	}{
+		{name: "exec excluded", excluded: []string{instrumentedsql.OpSQLConnExec}, want: 1},
+		{name: "everything but bad connections excluded", excluded: allOps[:len(allOps)-1], want: 1},
+		{name: "bad connections excluded", excluded: []string{instrumentedsql.OpSQLBadConn}, want: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := instrumentedsql.WrapDriver(&fakeDriver{badConns: 1}, instrumentedsql.WithOpsExcluded(test.excluded...))
+
+			db := openDB(t, d)
+			defer db.Close()
+
+			if _, err := db.ExecContext(context.Background(), "UPDATE t SET a = 1"); err != nil {
+				t.Fatal(err)
+			}
+			if n := d.BadConnCount(); n != test.want {
+				t.Errorf("expected bad conn count of %d, got %d", test.want, n)
+			}
+		})
+	}
+}
+
+// valuesCtx cannot be hashed, and neither can a structCtx holding it even though its type is comparable
+type valuesCtx struct {
+	context.Context
+	values map[string]string
+}
+
+type structCtx struct {
+	context.Context
+}
+
+func TestBadConnWithUnhashableContext(t *testing.T) {
+	d := instrumentedsql.WrapDriver(&fakeDriver{badConns: 1}, instrumentedsql.WithTracer(instrumentedsqltest.NewRecordingTracer()))
+
+	db := openDB(t, d)
+	defer db.Close()
+
+	ctx := structCtx{valuesCtx{Context: context.Background(), values: map[string]string{}}}
+	if _, err := db.ExecContext(ctx, "UPDATE t SET a = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.BadConnCount(); n != 1 {
+		t.Errorf("expected bad conn count of 1, got %d", n)
+	}
+}
+
+func TestNewRootSpanIfMissing(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []instrumentedsql.Opt
+		ctx        context.Context
+		wantRoot   bool
+		wantParent string
+	}{
+		{
+			name: "without the option",
+			ctx:  context.Background(),
+		},
+		{
+			name:     "with the option and no span in the context",
+			opts:     []instrumentedsql.Opt{instrumentedsql.WithNewRootSpanIfMissing()},
+			ctx:      context.Background(),
+			wantRoot: true,
+		},
+		{
+			name:       "with the option and a span in the context",
+			opts:       []instrumentedsql.Opt{instrumentedsql.WithNewRootSpanIfMissing()},
+			ctx:        instrumentedsqltest.ContextWithSpan(context.Background(), "job"),
+			wantParent: "job",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tracer := instrumentedsqltest.NewRecordingTracer()
+			d := instrumentedsql.WrapDriver(&fakeDriver{}, append(test.opts, instrumentedsql.WithTracer(tracer))...)
+
+			db := openDB(t, d)
+			defer db.Close()
+
+			if _, err := db.ExecContext(test.ctx, "UPDATE t SET a = 1"); err != nil {
+				t.Fatal(err)
+			}
+
+			span := tracer.AssertSpan(t, instrumentedsql.OpSQLConnExec)
+			if span.Root != test.wantRoot {
+				t.Errorf("expected root to be %v, got %v", test.wantRoot, span.Root)
+			}
+			if span.Parent != test.wantParent {
+				t.Errorf("expected parent %q, got %q", test.wantParent, span.Parent)
+			}
+		})
+	}
+}
+
+func TestContextErrorsAreClassified(t *testing.T) {
+	tracer := instrumentedsqltest.NewRecordingTracer()
+	logger := instrumentedsqltest.NewRecordingLogger()
+	d := instrumentedsql.WrapDriver(&fakeDriver{}, instrumentedsql.WithTracer(tracer), instrumentedsql.WithLogger(logger))
+
+	// database/sql checks the context before calling into the driver, so the connection is used directly
+	conn, err := d.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	tx, err := conn.(driver.ConnBeginTx).BeginTx(ctx, driver.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt, err := conn.(driver.ConnPrepareContext).PrepareContext(ctx, "UPDATE t SET a = 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if _, err := stmt.(driver.StmtExecContext).ExecContext(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.(driver.ExecerContext).ExecContext(ctx, "UPDATE t SET a = 1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, op := range []string{
+		instrumentedsql.OpSQLTxBegin,
+		instrumentedsql.OpSQLPrepare,
+		instrumentedsql.OpSQLStmtExec,
+		instrumentedsql.OpSQLConnExec,
+		instrumentedsql.OpSQLTxCommit,
+	} {
+		span := tracer.AssertSpan(t, op)
+		if _, ok := span.Label("deadline_remaining"); !ok {
+			t.Errorf("expected span %q to be labelled with the remaining deadline, got %v", op, span.Labels)
+		}
+		if _, ok := span.Label("err_class"); ok {
+			t.Errorf("expected span %q not to have an error class, got %v", op, span.Labels)
+		}
+	}
+
+	cancel()
+	if _, err := conn.(driver.ConnBeginTx).BeginTx(ctx, driver.TxOptions{}); err != errInterrupted {
+		t.Fatalf("expected %v, got %v", errInterrupted, err)
+	}
+	if _, err := stmt.(driver.StmtExecContext).ExecContext(ctx, nil); err != errInterrupted {
+		t.Fatalf("expected %v, got %v", errInterrupted, err)
+	}
+	if _, err := conn.(driver.ExecerContext).ExecContext(ctx, "UPDATE t SET a = 1", nil); err != errInterrupted {
+		t.Fatalf("expected %v, got %v", errInterrupted, err)
+	}
+
+	for _, op := range []string{instrumentedsql.OpSQLTxBegin, instrumentedsql.OpSQLStmtExec, instrumentedsql.OpSQLConnExec} {
+		tracer.AssertSpanLabel(t, op, "err_class", instrumentedsql.ErrClassCancelled)
+		logger.AssertLoggedValue(t, op, "err_class", instrumentedsql.ErrClassCancelled)
+	}
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	if _, err := conn.(driver.ExecerContext).ExecContext(expired, "UPDATE t SET a = 1", nil); err != errInterrupted {
+		t.Fatalf("expected %v, got %v", errInterrupted, err)
+	}
+	tracer.AssertSpanLabel(t, instrumentedsql.OpSQLConnExec, "err_class", instrumentedsql.ErrClassDeadlineExceeded)
+	logger.AssertLoggedValue(t, instrumentedsql.OpSQLConnExec, "err_class", instrumentedsql.ErrClassDeadlineExceeded)
+}
+
+func TestNewRootSpanIsPropagated(t *testing.T) {
+	tracer := instrumentedsqltest.NewRecordingTracer()
+	d := instrumentedsql.WrapDriver(&fakeDriver{rows: 3}, instrumentedsql.WithTracer(tracer), instrumentedsql.WithNewRootSpanIfMissing())
+
+	db := openDB(t, d)
+	defer db.Close()
+
+	// Connect up front, connecting starts a root span of its own
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	// assertSingleTrace fails the test unless the only root span recorded is named root, everything else is its child
+	// and it was finished last
+	assertSingleTrace := func(t *testing.T, root string) {
+		t.Helper()
+
+		spans := tracer.Spans()
+		if len(spans) == 0 || spans[len(spans)-1].Name != root {
+			t.Errorf("expected %q to be finished after its children, got %v", root, spans)
+		}
+		for _, span := range spans {
+			switch {
+			case span.Name == root && !span.Root:
+				t.Errorf("expected %q to be a root span, got a child of %q", span.Name, span.Parent)
+			case span.Name != root && span.Root:
+				t.Errorf("expected %q to be a child of %q, got a root span", span.Name, root)
+			case span.Name != root && span.Parent != root:
+				t.Errorf("expected %q to be a child of %q, got a child of %q", span.Name, root, span.Parent)
+			}
+		}
+		if len(tracer.SpansNamed(root)) != 1 {
+			t.Errorf("expected a single %q span, got %v", root, spans)
+		}
+	}
+
+	t.Run("query", func(t *testing.T) {
+		tracer.Reset()
+
+		rows, err := db.QueryContext(context.Background(), "SELECT n FROM t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for rows.Next() {
+			n++
+		}
+		if err := rows.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if n != 3 {
+			t.Fatalf("expected 3 rows, got %d", n)
+		}
+
+		assertSingleTrace(t, instrumentedsql.OpSQLConnQuery)
+		if spans := tracer.SpansNamed(instrumentedsql.OpSQLRowsNext); len(spans) != 4 {
+			t.Errorf("expected a span for every row and the end of the rows, got %d", len(spans))
+		}
+	})
+
+	t.Run("transaction", func(t *testing.T) {
+		tracer.Reset()
+
+		tx, err := db.BeginTx(context.Background(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.ExecContext(context.Background(), "UPDATE t SET a = 1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+
+		assertSingleTrace(t, instrumentedsql.OpSQLTxBegin)
+		tracer.AssertSpan(t, instrumentedsql.OpSQLConnExec)
+		tracer.AssertSpan(t, instrumentedsql.OpSQLTxCommit)
+
+		// Once the transaction has ended, the connection starts root spans again
+		tracer.Reset()
+		if _, err := db.ExecContext(context.Background(), "UPDATE t SET a = 1"); err != nil {
+			t.Fatal(err)
+		}
+		if !tracer.AssertSpan(t, instrumentedsql.OpSQLConnExec).Root {
+			t.Error("expected an exec after the transaction to start a root span")
+		}
+	})
+
+	t.Run("prepared statement", func(t *testing.T) {
+		tracer.Reset()
+
+		stmt, err := db.PrepareContext(context.Background(), "UPDATE t SET a = ?")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+		for i := 0; i < 3; i++ {
+			if _, err := stmt.ExecContext(context.Background(), i); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if !tracer.AssertSpan(t, instrumentedsql.OpSQLPrepare).Root {
+			t.Error("expected preparing the statement to start a root span")
+		}
+		execs := tracer.SpansNamed(instrumentedsql.OpSQLStmtExec)
+		if len(execs) != 3 {
+			t.Fatalf("expected a span for every execution, got %d", len(execs))
+		}
+		for _, span := range execs {
+			if !span.Root {
+				t.Errorf("expected every execution to start a root span, got a child of %q", span.Parent)
+			}
+		}
+
+		// Executed as part of a transaction, the statement is part of its trace
+		tracer.Reset()
+		tx, err := db.BeginTx(context.Background(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.StmtContext(context.Background(), stmt).ExecContext(context.Background(), 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		if parent := tracer.AssertSpan(t, instrumentedsql.OpSQLStmtExec).Parent; parent != instrumentedsql.OpSQLTxBegin {
+			t.Errorf("expected an execution in a transaction to be a child of %q, got a child of %q", instrumentedsql.OpSQLTxBegin, parent)
+		}
+	})
+
+	t.Run("without context", func(t *testing.T) {
+		tracer.Reset()
+
+		conn, err := d.Open("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		stmt, err := conn.Prepare("UPDATE t SET a = 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := stmt.Exec(nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := stmt.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, span := range tracer.Spans() {
+			if span.Root {
+				t.Errorf("expected no root span for operations run without a context, got %q", span.Name)
+			}
+		}
+	})
+}
+
+func TestOpsExcludedSkipsWrapping(t *testing.T) {
+	d := instrumentedsql.WrapDriver(&fakeDriver{}, instrumentedsql.WithOpsExcluded(allOps...))
+	conn, err := d.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := conn.(*fakeConn); !ok {
+		t.Errorf("expected the parent connection when all ops are excluded, got %T", conn)
+	}
+
+	d = instrumentedsql.WrapDriver(&fakeDriver{}, instrumentedsql.WithOpsExcluded(instrumentedsql.OpSQLTxCommit, instrumentedsql.OpSQLTxRollback))
+	conn, err = d.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := conn.(instrumentedsql.WrappedConn); !ok {
+		t.Fatalf("expected a wrapped connection, got %T", conn)
+	}
+	tx, err := conn.(driver.ConnBeginTx).BeginTx(context.Background(), driver.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tx.(fakeTx); !ok {
+		t.Errorf("expected the parent transaction when commit and rollback are excluded, got %T", tx)
+	}
+}
+
+// openDB opens a database through a connector of d, which unlike sql.Open does not require registering it
+func openDB(t testing.TB, d instrumentedsql.WrappedDriver) *sql.DB {
+	connector, err := d.OpenConnector("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sql.OpenDB(connector)
+}
+
+// fakeDriver is an in-memory driver whose connections succeed at everything,
+// except for the first badConns operations which fail with driver.ErrBadConn.
+// Queries return a single column and as many rows as set, or a single one.
+type fakeDriver struct {
+	mu       sync.Mutex
+	badConns int
+	rows     int
+}
+
+func (d *fakeDriver) newRows() *fakeRows {
+	if d.rows == 0 {
+		return &fakeRows{left: 1}
+	}
+	return &fakeRows{left: d.rows}
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{driver: d}, nil
+}
+
+// errInterrupted is returned by the fake for operations run with a done context,
+// like most drivers it does not return the context error itself
+var errInterrupted = errors.New("fake: operation interrupted")
+
+// failContext fails with errInterrupted if ctx is done, and like fail otherwise
+func (d *fakeDriver) failContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return errInterrupted
+	}
+	return d.fail()
+}
+
+func (d *fakeDriver) fail() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.badConns > 0 {
+		d.badConns--
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+type fakeConn struct {
+	driver *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	if err := c.driver.fail(); err != nil {
+		return nil, err
+	}
+	return &fakeStmt{conn: c}, nil
+}
+
+func (c *fakeConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := c.driver.failContext(ctx); err != nil {
+		return nil, err
+	}
+	return &fakeStmt{conn: c}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	if err := c.driver.fail(); err != nil {
+		return nil, err
+	}
+	return fakeTx{}, nil
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := c.driver.failContext(ctx); err != nil {
+		return nil, err
+	}
+	return fakeTx{}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.driver.failContext(ctx); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.driver.failContext(ctx); err != nil {
+		return nil, err
+	}
+	return c.driver.newRows(), nil
+}
+
+type fakeStmt struct {
+	conn *fakeConn
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if err := s.conn.driver.fail(); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if err := s.conn.driver.fail(); err != nil {
+		return nil, err
+	}
+	return s.conn.driver.newRows(), nil
+}
+
+func (s *fakeStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if err := s.conn.driver.failContext(ctx); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if err := s.conn.driver.failContext(ctx); err != nil {
+		return nil, err
+	}
+	return s.conn.driver.newRows(), nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error {
+	return nil
+}
+
+func (fakeTx) Rollback() error {
+	return nil
+}
+
+type fakeRows struct {
+	left int
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"n"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.left == 0 {
+		return io.EOF
+	}
+	r.left--
+	dest[0] = int64(1)
+	return nil
+}
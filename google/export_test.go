@@ -0,0 +1,9 @@
+package google
+
+import "github.com/luna-duclos/instrumentedsql"
+
+// FinishWait finishes s like Finish does, but uploads its trace right away when it is a root span and waits for the upload,
+// instead of leaving the client to upload it in the background
+func FinishWait(s instrumentedsql.Span) error {
+	return s.(span).parent.FinishWait()
+}
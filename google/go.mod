@@ -8,4 +8,5 @@ require (
 	github.com/go-sql-driver/mysql v1.5.0
 	github.com/luna-duclos/instrumentedsql v1.1.3
 	go.opencensus.io v0.22.1 // indirect
+	google.golang.org/api v0.9.0
 )
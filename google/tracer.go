@@ -3,14 +3,32 @@ package google
 import (
 	"context"
 	"database/sql/driver"
+	"unicode/utf8"
 
 	"cloud.google.com/go/trace"
 
 	"github.com/luna-duclos/instrumentedsql"
 )
 
+const (
+	labelQuery    = "query"
+	labelArgs     = "args"
+	labelErrClass = "err_class"
+
+	// maxLabelLength is the longest label value Cloud Trace accepts, longer ones are truncated
+	maxLabelLength = 16*1024 - 1
+)
+
+// Canonical status code names, Cloud Trace has no status field so these are reported through trace.LabelErrorName
+const (
+	statusCancelled        = "CANCELLED"
+	statusDeadlineExceeded = "DEADLINE_EXCEEDED"
+	statusUnknown          = "UNKNOWN"
+)
+
 type tracer struct {
 	traceOrphans bool
+	client       *trace.Client
 }
 
 type span struct {
@@ -22,6 +40,12 @@ type span struct {
 // if traceOrphans is set to true, then spans with no parent will be traced anyway, if false, they will not be.
 func NewTracer(traceOrphans bool) instrumentedsql.Tracer { return tracer{traceOrphans: traceOrphans} }
 
+// NewTracerWithClient returns a tracer like NewTracer, which also starts new root spans with client,
+// it is required for instrumentedsql.WithNewRootSpanIfMissing to have an effect
+func NewTracerWithClient(client *trace.Client, traceOrphans bool) instrumentedsql.Tracer {
+	return tracer{traceOrphans: traceOrphans, client: client}
+}
+
 // GetSpan fetches a span from the context and wraps it
 func (t tracer) GetSpan(ctx context.Context) instrumentedsql.Span {
 	if ctx == nil {
@@ -31,6 +55,17 @@ func (t tracer) GetSpan(ctx context.Context) instrumentedsql.Span {
 	return span{parent: trace.FromContext(ctx), tracer: t}
 }
 
+// HasSpan reports whether ctx holds a Cloud Trace span.
+// A tracer made by NewTracer has no client to start root spans with, so it reports true for every context.
+func (t tracer) HasSpan(ctx context.Context) bool {
+	return t.client == nil || (ctx != nil && trace.FromContext(ctx) != nil)
+}
+
+// NewRootSpan starts a new root span with the client of the tracer
+func (t tracer) NewRootSpan(ctx context.Context, name string) instrumentedsql.Span {
+	return span{parent: t.client.NewSpan(name), tracer: t}
+}
+
 func (s span) NewChild(name string) instrumentedsql.Span {
 	if s.parent == nil && !s.traceOrphans {
 		return s
@@ -40,6 +75,14 @@ func (s span) NewChild(name string) instrumentedsql.Span {
 }
 
 func (s span) SetLabel(k, v string) {
+	switch k {
+	case labelQuery, labelArgs:
+		v = truncate(v, maxLabelLength)
+	case labelErrClass:
+		// Cloud Trace keeps a single error name, the class replaces the one SetError guessed from the error alone
+		s.parent.SetLabel(trace.LabelErrorName, errClassStatus(v))
+	}
+
 	s.parent.SetLabel(k, v)
 }
 
@@ -49,8 +92,49 @@ func (s span) SetError(err error) {
 	}
 
 	s.parent.SetLabel("err", err.Error())
+	s.parent.SetLabel(trace.LabelErrorName, errStatus(err))
+	s.parent.SetLabel(trace.LabelErrorMessage, truncate(err.Error(), maxLabelLength))
 }
 
 func (s span) Finish() {
 	s.parent.Finish()
 }
+
+// errStatus maps err to the name of a canonical status code
+func errStatus(err error) string {
+	switch err {
+	case context.Canceled:
+		return statusCancelled
+	case context.DeadlineExceeded:
+		return statusDeadlineExceeded
+	default:
+		return statusUnknown
+	}
+}
+
+// errClassStatus maps the err_class label set by instrumentedsql to the name of a canonical status code
+func errClassStatus(class string) string {
+	switch class {
+	case "cancelled":
+		return statusCancelled
+	case "deadline_exceeded":
+		return statusDeadlineExceeded
+	default:
+		return statusUnknown
+	}
+}
+
+// truncate shortens s to fit a label of at most n bytes, cutting on a rune boundary
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	const ellipsis = "..."
+	cut := n - len(ellipsis)
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+
+	return s[:cut] + ellipsis
+}
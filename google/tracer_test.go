@@ -1,11 +1,22 @@
 package google_test
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
 
+	"cloud.google.com/go/trace"
 	"github.com/go-sql-driver/mysql"
 	"github.com/luna-duclos/instrumentedsql"
 	"github.com/luna-duclos/instrumentedsql/google"
+	cloudtrace "google.golang.org/api/cloudtrace/v1"
+	"google.golang.org/api/option"
 )
 
 // WrapDriverGoogle demonstrates how to call wrapDriver and register a new driver.
@@ -17,3 +28,127 @@ func ExampleWrapDriver_google() {
 	// Proceed to handle connection errors and use the database as usual
 	_, _ = db, err
 }
+
+// uploadRecorder stands in for the Cloud Trace API and keeps the traces uploaded to it
+type uploadRecorder struct {
+	mu     sync.Mutex
+	traces []*cloudtrace.Trace
+}
+
+func (r *uploadRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body cloudtrace.Traces
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.traces = append(r.traces, body.Traces...)
+
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("{}"))}, nil
+}
+
+// span returns the labels of the span named name in the last uploaded trace
+func (r *uploadRecorder) span(t *testing.T, name string) map[string]string {
+	t.Helper()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.traces) == 0 {
+		t.Fatal("expected a trace to be uploaded")
+	}
+	for _, span := range r.traces[len(r.traces)-1].Spans {
+		if span.Name == name {
+			return span.Labels
+		}
+	}
+	t.Fatalf("expected a span named %q to be uploaded", name)
+	return nil
+}
+
+func TestSpanErrorAndLabels(t *testing.T) {
+	recorder := &uploadRecorder{}
+	client, err := trace.NewClient(context.Background(), "project", option.WithHTTPClient(&http.Client{Transport: recorder}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := google.NewTracer(false)
+	longQuery := "SELECT " + strings.Repeat("a, ", 8*1024) + "b FROM t"
+
+	tests := []struct {
+		name     string
+		err      error
+		errClass string
+		want     string
+	}{
+		{name: "success"},
+		{name: "context cancelled", err: context.Canceled, errClass: "cancelled", want: "CANCELLED"},
+		{name: "driver error on expired context", err: errors.New("i/o timeout"), errClass: "deadline_exceeded", want: "DEADLINE_EXCEEDED"},
+		{name: "server error", err: errors.New("syntax error"), errClass: "server_error", want: "UNKNOWN"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := client.NewSpan("root")
+			ctx := trace.NewContext(context.Background(), root)
+
+			span := tr.GetSpan(ctx).NewChild(test.name)
+			span.SetLabel("query", longQuery)
+			span.SetError(test.err)
+			if test.errClass != "" {
+				span.SetLabel("err_class", test.errClass)
+			}
+			span.Finish()
+			if err := root.FinishWait(); err != nil {
+				t.Fatal(err)
+			}
+
+			labels := recorder.span(t, test.name)
+			if got := labels[trace.LabelErrorName]; got != test.want {
+				t.Errorf("expected %s to be %q, got %q", trace.LabelErrorName, test.want, got)
+			}
+			if test.err != nil && labels[trace.LabelErrorMessage] != test.err.Error() {
+				t.Errorf("expected %s to be %q, got %q", trace.LabelErrorMessage, test.err.Error(), labels[trace.LabelErrorMessage])
+			}
+			if query := labels["query"]; len(query) >= 16*1024 || !strings.HasPrefix(longQuery, strings.TrimSuffix(query, "...")) {
+				t.Errorf("expected the query label to be truncated below 16KiB, got %d bytes", len(query))
+			}
+		})
+	}
+}
+
+func TestNewRootSpan(t *testing.T) {
+	recorder := &uploadRecorder{}
+	client, err := trace.NewClient(context.Background(), "project", option.WithHTTPClient(&http.Client{Transport: recorder}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The instrumentedsql version this module builds against predates RootSpanTracer, it is checked through a local interface
+	type rootSpanTracer interface {
+		HasSpan(ctx context.Context) bool
+		NewRootSpan(ctx context.Context, name string) instrumentedsql.Span
+	}
+
+	if !google.NewTracer(false).(rootSpanTracer).HasSpan(context.Background()) {
+		t.Error("expected a tracer without a client to never start root spans")
+	}
+
+	tr := google.NewTracerWithClient(client, false).(rootSpanTracer)
+	if tr.HasSpan(context.Background()) {
+		t.Error("expected an empty context not to carry a span")
+	}
+	if !tr.HasSpan(trace.NewContext(context.Background(), client.NewSpan("parent"))) {
+		t.Error("expected a context with a span to carry one")
+	}
+
+	root := tr.NewRootSpan(context.Background(), "sql-conn-exec")
+	root.SetLabel("query", "SELECT 1")
+	if err := google.FinishWait(root); err != nil {
+		t.Fatal(err)
+	}
+	if labels := recorder.span(t, "sql-conn-exec"); labels["query"] != "SELECT 1" {
+		t.Errorf("expected the root span to be uploaded with its labels, got %v", labels)
+	}
+}
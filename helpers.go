@@ -10,7 +10,7 @@ import (
 	"time"
 )
 
-func formatArgs(args interface{}) string {
+func formatArgs(args interface{}, sanitize ArgSanitizer) string {
 	argsVal := reflect.ValueOf(args)
 	if argsVal.Kind() != reflect.Slice {
 		return "<unknown>"
@@ -18,7 +18,11 @@ func formatArgs(args interface{}) string {
 
 	strArgs := make([]string, 0, argsVal.Len())
 	for i := 0; i < argsVal.Len(); i++ {
-		strArgs = append(strArgs, formatArg(argsVal.Index(i).Interface()))
+		arg := argsVal.Index(i).Interface()
+		if sanitize != nil {
+			arg = sanitizeArg(arg, i, sanitize)
+		}
+		strArgs = append(strArgs, formatArg(arg))
 	}
 
 	return fmt.Sprintf("{%s}", strings.Join(strArgs, ", "))
@@ -33,7 +37,7 @@ func formatArg(arg interface{}) string {
 		strArg = fmt.Sprintf("[%T %q]", arg, arg)
 	case driver.NamedValue:
 		if arg.Name != "" {
-			strArg = fmt.Sprintf("[%T %s=%v]", arg.Value, arg.Name, formatArg(arg.Value))
+			strArg = fmt.Sprintf("%s=%s", arg.Name, formatArg(arg.Value))
 		} else {
 			strArg = formatArg(arg.Value)
 		}
@@ -44,20 +48,102 @@ func formatArg(arg interface{}) string {
 	return strArg
 }
 
-func logQuery(ctx context.Context, opts opts, op, query string, err error, args interface{}, since time.Time) {
+// sanitizeArg passes the argument at index i to sanitize, named values are passed by name and ordinal
+func sanitizeArg(arg interface{}, i int, sanitize ArgSanitizer) interface{} {
+	named, ok := arg.(driver.NamedValue)
+	if !ok {
+		return sanitize("", i+1, arg)
+	}
+
+	ordinal := named.Ordinal
+	if ordinal == 0 {
+		ordinal = i + 1
+	}
+	named.Value = sanitize(named.Name, ordinal, named.Value)
+
+	return named
+}
+
+func logQuery(ctx context.Context, opts *opts, op, query string, err error, args interface{}, since time.Time) {
 	keyvals := []interface{}{
 		"query", query,
 		"err", err,
 		"duration", time.Since(since),
 	}
 
+	if class := classifyError(ctx, err); class != "" {
+		keyvals = append(keyvals, "err_class", class)
+	}
 	if !opts.OmitArgs && args != nil {
-		keyvals = append(keyvals, "args", formatArgs(args))
+		keyvals = append(keyvals, "args", formatArgs(args, opts.SanitizeArg))
 	}
 
 	opts.Log(ctx, op, keyvals...)
 }
 
+// logOp logs the outcome of an op that does not run a query, along with the ErrClass of err if it has one
+func logOp(ctx context.Context, opts *opts, op string, err error, since time.Time) {
+	keyvals := []interface{}{
+		"err", err,
+		"duration", time.Since(since),
+	}
+
+	if class := classifyError(ctx, err); class != "" {
+		keyvals = append(keyvals, "err_class", class)
+	}
+
+	opts.Log(ctx, op, keyvals...)
+}
+
+// setDeadlineLabel records on span how much time was left before the context deadline when the operation started
+func setDeadlineLabel(ctx context.Context, span Span) {
+	if ctx == nil {
+		return
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		span.SetLabel("deadline_remaining", time.Until(deadline).String())
+	}
+}
+
+// setSpanError sets err on span and labels it with the ErrClass it falls into.
+// Attempts that failed with driver.ErrBadConn are labelled bad_conn, so they can be told apart from the retry that follows.
+func setSpanError(ctx context.Context, span Span, err error) {
+	span.SetError(err)
+
+	if class := classifyError(ctx, err); class != "" {
+		span.SetLabel("err_class", class)
+	}
+	if err == driver.ErrBadConn {
+		span.SetLabel("bad_conn", "true")
+	}
+}
+
+// classifyError returns the ErrClass of err, or an empty string if err is not an error worth reporting.
+// Drivers rarely return the context errors as is, so the state of the context is checked as well.
+func classifyError(ctx context.Context, err error) string {
+	if err == nil || err == driver.ErrSkip {
+		return ""
+	}
+
+	if err == context.Canceled {
+		return ErrClassCancelled
+	}
+	if err == context.DeadlineExceeded {
+		return ErrClassDeadlineExceeded
+	}
+
+	if ctx != nil {
+		switch ctx.Err() {
+		case context.Canceled:
+			return ErrClassCancelled
+		case context.DeadlineExceeded:
+			return ErrClassDeadlineExceeded
+		}
+	}
+
+	return ErrClassServerError
+}
 
 // namedValueToValue is a helper function copied from the database/sql package
 func namedValueToValue(named []driver.NamedValue) ([]driver.Value, error) {
@@ -0,0 +1,97 @@
+package instrumentedsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClassifyError(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	serverErr := errors.New("pq: canceling statement due to user request")
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want string
+	}{
+		{name: "no error", ctx: context.Background(), err: nil, want: ""},
+		{name: "skip", ctx: context.Background(), err: driver.ErrSkip, want: ""},
+		{name: "context canceled error", ctx: nil, err: context.Canceled, want: ErrClassCancelled},
+		{name: "context deadline error", ctx: nil, err: context.DeadlineExceeded, want: ErrClassDeadlineExceeded},
+		{name: "driver error on cancelled context", ctx: cancelled, err: serverErr, want: ErrClassCancelled},
+		{name: "driver error on expired context", ctx: expired, err: serverErr, want: ErrClassDeadlineExceeded},
+		{name: "driver error on live context", ctx: context.Background(), err: serverErr, want: ErrClassServerError},
+		{name: "driver error without context", ctx: nil, err: serverErr, want: ErrClassServerError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := classifyError(test.ctx, test.err); got != test.want {
+				t.Errorf("expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestFormatArgs(t *testing.T) {
+	redactPassword := func(name string, ordinal int, value interface{}) interface{} {
+		if name == "password" || (name == "" && ordinal == 2) {
+			return "<redacted>"
+		}
+		return value
+	}
+
+	tests := []struct {
+		name     string
+		args     interface{}
+		sanitize ArgSanitizer
+		want     string
+	}{
+		{
+			name: "positional values",
+			args: []driver.Value{int64(1), "a", []byte("abc")},
+			want: `{[int64 1], [string "a"], [[]uint8 len:3]}`,
+		},
+		{
+			name: "unnamed named values",
+			args: []driver.NamedValue{{Ordinal: 1, Value: int64(1)}, {Ordinal: 2, Value: "a"}},
+			want: `{[int64 1], [string "a"]}`,
+		},
+		{
+			name: "named values",
+			args: []driver.NamedValue{{Name: "id", Ordinal: 1, Value: int64(1)}, {Name: "user", Ordinal: 2, Value: "a"}},
+			want: `{id=[int64 1], user=[string "a"]}`,
+		},
+		{
+			name:     "sanitized named values",
+			args:     []driver.NamedValue{{Name: "user", Ordinal: 1, Value: "a"}, {Name: "password", Ordinal: 2, Value: "hunter2"}},
+			sanitize: redactPassword,
+			want:     `{user=[string "a"], password=[string "<redacted>"]}`,
+		},
+		{
+			name:     "sanitized positional values",
+			args:     []driver.Value{"a", "hunter2"},
+			sanitize: redactPassword,
+			want:     `{[string "a"], [string "<redacted>"]}`,
+		},
+		{
+			name: "not a slice",
+			args: "a",
+			want: "<unknown>",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := formatArgs(test.args, test.sanitize); got != test.want {
+				t.Errorf("expected %s, got %s", test.want, got)
+			}
+		})
+	}
+}
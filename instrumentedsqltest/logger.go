@@ -0,0 +1,128 @@
+package instrumentedsqltest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/luna-duclos/instrumentedsql"
+)
+
+// LogRecord is a single call to Log captured by a RecordingLogger
+type LogRecord struct {
+	Msg     string
+	Keyvals []interface{}
+}
+
+// Value returns the value logged for key and whether it was present
+func (r LogRecord) Value(key string) (interface{}, bool) {
+	for i := 0; i+1 < len(r.Keyvals); i += 2 {
+		if k, ok := r.Keyvals[i].(string); ok && k == key {
+			return r.Keyvals[i+1], true
+		}
+	}
+	return nil, false
+}
+
+// RecordingLogger is a logger that keeps every log record in memory.
+// The zero value is ready to use and it is safe for concurrent use.
+type RecordingLogger struct {
+	mu      sync.Mutex
+	records []LogRecord
+}
+
+// Compile time validation that our types implement the expected interfaces
+var (
+	_ instrumentedsql.Logger = &RecordingLogger{}
+)
+
+// NewRecordingLogger returns a new, empty RecordingLogger
+func NewRecordingLogger() *RecordingLogger {
+	return &RecordingLogger{}
+}
+
+// Log records msg and keyvals
+func (l *RecordingLogger) Log(ctx context.Context, msg string, keyvals ...interface{}) {
+	kv := make([]interface{}, len(keyvals))
+	copy(kv, keyvals)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.records = append(l.records, LogRecord{Msg: msg, Keyvals: kv})
+}
+
+// Records returns all log records in the order they were logged
+func (l *RecordingLogger) Records() []LogRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	records := make([]LogRecord, len(l.records))
+	copy(records, l.records)
+	return records
+}
+
+// RecordsWithMessage returns all log records with the given message
+func (l *RecordingLogger) RecordsWithMessage(msg string) []LogRecord {
+	var records []LogRecord
+	for _, r := range l.Records() {
+		if r.Msg == msg {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// Reset discards all log records
+func (l *RecordingLogger) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.records = nil
+}
+
+// AssertLogged fails the test if nothing was logged with the given message, it returns the last such record otherwise
+func (l *RecordingLogger) AssertLogged(tb testing.TB, msg string) LogRecord {
+	tb.Helper()
+
+	records := l.RecordsWithMessage(msg)
+	if len(records) == 0 {
+		tb.Fatalf("expected a log record with message %q, got %v", msg, recordMessages(l.Records()))
+		return LogRecord{}
+	}
+	return records[len(records)-1]
+}
+
+// AssertNotLogged fails the test if anything was logged with the given message
+func (l *RecordingLogger) AssertNotLogged(tb testing.TB, msg string) {
+	tb.Helper()
+
+	if n := len(l.RecordsWithMessage(msg)); n != 0 {
+		tb.Errorf("expected no log record with message %q, got %d", msg, n)
+	}
+}
+
+// AssertLoggedValue fails the test if the last record with the given message does not have key set to a value
+// whose default formatting equals want
+func (l *RecordingLogger) AssertLoggedValue(tb testing.TB, msg, key string, want interface{}) {
+	tb.Helper()
+
+	r := l.AssertLogged(tb, msg)
+	got, ok := r.Value(key)
+	if !ok {
+		tb.Errorf("expected log record %q to have key %q, got %v", msg, key, r.Keyvals)
+		return
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		tb.Errorf("expected log record %q key %q to be %v, got %v", msg, key, want, got)
+	}
+}
+
+func recordMessages(records []LogRecord) []string {
+	msgs := make([]string, 0, len(records))
+	for _, r := range records {
+		msgs = append(msgs, r.Msg)
+	}
+	return msgs
+}
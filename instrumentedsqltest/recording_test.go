@@ -0,0 +1,107 @@
+package instrumentedsqltest_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/luna-duclos/instrumentedsql"
+	"github.com/luna-duclos/instrumentedsql/instrumentedsqltest"
+)
+
+func TestRecordingTracerAndLogger(t *testing.T) {
+	tracer := instrumentedsqltest.NewRecordingTracer()
+	logger := instrumentedsqltest.NewRecordingLogger()
+
+	connector, err := instrumentedsql.WrapDriver(stubDriver{},
+		instrumentedsql.WithTracer(tracer),
+		instrumentedsql.WithLogger(logger),
+	).OpenConnector("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, "UPDATE t SET a = ?", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "fail"); err != errStub {
+		t.Fatalf("expected %v, got %v", errStub, err)
+	}
+
+	tracer.AssertSpanLabel(t, instrumentedsql.OpSQLConnExec, "query", "fail")
+	tracer.AssertSpanError(t, instrumentedsql.OpSQLConnExec, errStub)
+	tracer.AssertNoSpan(t, instrumentedsql.OpSQLConnQuery)
+	if n := len(tracer.SpansNamed(instrumentedsql.OpSQLConnExec)); n != 2 {
+		t.Errorf("expected 2 exec spans, got %d", n)
+	}
+
+	first := tracer.SpansNamed(instrumentedsql.OpSQLConnExec)[0]
+	if first.Err != nil {
+		t.Errorf("expected first exec span to have no error, got %v", first.Err)
+	}
+	if args, _ := first.Label("args"); args != `{[string "x"]}` {
+		t.Errorf("unexpected args label %q", args)
+	}
+
+	logger.AssertLoggedValue(t, instrumentedsql.OpSQLConnExec, "query", "fail")
+	logger.AssertNotLogged(t, instrumentedsql.OpSQLConnQuery)
+
+	tracer.Reset()
+	logger.Reset()
+	if len(tracer.Spans()) != 0 || len(logger.Records()) != 0 {
+		t.Error("expected Reset to discard everything recorded")
+	}
+}
+
+var errStub = errors.New("stub failure")
+
+type stubDriver struct{}
+
+func (stubDriver) Open(name string) (driver.Conn, error) {
+	return stubConn{}, nil
+}
+
+type stubConn struct{}
+
+func (stubConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (stubConn) Close() error {
+	return nil
+}
+
+func (stubConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (stubConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if query == "fail" {
+		return nil, errStub
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (stubConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return stubRows{}, nil
+}
+
+type stubRows struct{}
+
+func (stubRows) Columns() []string {
+	return nil
+}
+
+func (stubRows) Close() error {
+	return nil
+}
+
+func (stubRows) Next(dest []driver.Value) error {
+	return io.EOF
+}
@@ -0,0 +1,232 @@
+// Package instrumentedsqltest provides an in-memory tracer and logger that record everything instrumentedsql emits,
+// so that code using a wrapped driver can assert on the instrumentation it produces.
+package instrumentedsqltest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luna-duclos/instrumentedsql"
+)
+
+// RecordedSpan is a finished span captured by a RecordingTracer.
+// Root is set for spans started through NewRootSpan, Parent holds the name of the parent span otherwise.
+type RecordedSpan struct {
+	Name     string
+	Parent   string
+	Root     bool
+	Labels   map[string]string
+	Err      error
+	Duration time.Duration
+}
+
+// Label returns the value of the label k and whether it was set
+func (s RecordedSpan) Label(k string) (string, bool) {
+	v, ok := s.Labels[k]
+	return v, ok
+}
+
+// RecordingTracer is a tracer that keeps every finished span in memory.
+// The zero value is ready to use and it is safe for concurrent use.
+type RecordingTracer struct {
+	mu    sync.Mutex
+	spans []RecordedSpan
+}
+
+type recordingSpan struct {
+	tracer *RecordingTracer
+	name   string
+	parent string
+	root   bool
+	start  time.Time
+
+	mu     sync.Mutex
+	labels map[string]string
+	err    error
+}
+
+// Compile time validation that our types implement the expected interfaces
+var (
+	_ instrumentedsql.RootSpanTracer = &RecordingTracer{}
+	_ instrumentedsql.Span           = &recordingSpan{}
+)
+
+// NewRecordingTracer returns a new, empty RecordingTracer
+func NewRecordingTracer() *RecordingTracer {
+	return &RecordingTracer{}
+}
+
+type spanNameKey struct{}
+
+// ContextWithSpan returns a context carrying a span with the given name,
+// spans started from it are recorded with name as their parent
+func ContextWithSpan(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, spanNameKey{}, name)
+}
+
+// GetSpan returns the span carried by ctx, only its children are recorded
+func (t *RecordingTracer) GetSpan(ctx context.Context) instrumentedsql.Span {
+	name, _ := spanName(ctx)
+	return &recordingSpan{tracer: t, name: name}
+}
+
+// HasSpan reports whether ctx was returned by ContextWithSpan
+func (t *RecordingTracer) HasSpan(ctx context.Context) bool {
+	_, ok := spanName(ctx)
+	return ok
+}
+
+// NewRootSpan starts a span that is recorded with Root set
+func (t *RecordingTracer) NewRootSpan(ctx context.Context, name string) instrumentedsql.Span {
+	return &recordingSpan{tracer: t, name: name, root: true, start: time.Now()}
+}
+
+// Spans returns all finished spans in the order they were finished
+func (t *RecordingTracer) Spans() []RecordedSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	spans := make([]RecordedSpan, len(t.spans))
+	copy(spans, t.spans)
+	return spans
+}
+
+// SpansNamed returns all finished spans with the given name
+func (t *RecordingTracer) SpansNamed(name string) []RecordedSpan {
+	var spans []RecordedSpan
+	for _, s := range t.Spans() {
+		if s.Name == name {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+// Reset discards all recorded spans
+func (t *RecordingTracer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.spans = nil
+}
+
+// AssertSpan fails the test if no span with the given name was finished, it returns the last one otherwise
+func (t *RecordingTracer) AssertSpan(tb testing.TB, name string) RecordedSpan {
+	tb.Helper()
+
+	spans := t.SpansNamed(name)
+	if len(spans) == 0 {
+		tb.Fatalf("expected a span named %q, got %v", name, spanNames(t.Spans()))
+		return RecordedSpan{}
+	}
+	return spans[len(spans)-1]
+}
+
+// AssertNoSpan fails the test if a span with the given name was finished
+func (t *RecordingTracer) AssertNoSpan(tb testing.TB, name string) {
+	tb.Helper()
+
+	if n := len(t.SpansNamed(name)); n != 0 {
+		tb.Errorf("expected no span named %q, got %d", name, n)
+	}
+}
+
+// AssertSpanLabel fails the test if the last span with the given name does not have label k set to v
+func (t *RecordingTracer) AssertSpanLabel(tb testing.TB, name, k, v string) {
+	tb.Helper()
+
+	span := t.AssertSpan(tb, name)
+	got, ok := span.Label(k)
+	if !ok {
+		tb.Errorf("expected span %q to have label %q, got %v", name, k, span.Labels)
+		return
+	}
+	if got != v {
+		tb.Errorf("expected span %q label %q to be %q, got %q", name, k, v, got)
+	}
+}
+
+// AssertSpanError fails the test if the last span with the given name did not record err
+func (t *RecordingTracer) AssertSpanError(tb testing.TB, name string, err error) {
+	tb.Helper()
+
+	span := t.AssertSpan(tb, name)
+	if span.Err != err {
+		tb.Errorf("expected span %q to have error %v, got %v", name, err, span.Err)
+	}
+}
+
+func (t *RecordingTracer) record(s RecordedSpan) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.spans = append(t.spans, s)
+}
+
+func (s *recordingSpan) NewChild(name string) instrumentedsql.Span {
+	return &recordingSpan{tracer: s.tracer, name: name, parent: s.name, start: time.Now()}
+}
+
+func (s *recordingSpan) SetLabel(k, v string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.labels == nil {
+		s.labels = make(map[string]string)
+	}
+	s.labels[k] = v
+}
+
+func (s *recordingSpan) SetError(err error) {
+	if err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+}
+
+func (s *recordingSpan) Finish() {
+	// Spans returned by GetSpan stand in for whatever span the context carries, they are never recorded
+	if s.start.IsZero() {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	labels := make(map[string]string, len(s.labels))
+	for k, v := range s.labels {
+		labels[k] = v
+	}
+
+	s.tracer.record(RecordedSpan{
+		Name:     s.name,
+		Parent:   s.parent,
+		Root:     s.root,
+		Labels:   labels,
+		Err:      s.err,
+		Duration: time.Since(s.start),
+	})
+}
+
+func spanName(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+
+	name, ok := ctx.Value(spanNameKey{}).(string)
+	return name, ok
+}
+
+func spanNames(spans []RecordedSpan) []string {
+	names := make([]string, 0, len(spans))
+	for _, s := range spans {
+		names = append(names, s.Name)
+	}
+	return names
+}
@@ -18,3 +18,23 @@ type LoggerFunc func(ctx context.Context, msg string, keyvals ...interface{})
 func (f LoggerFunc) Log(ctx context.Context, msg string, keyvals ...interface{}) {
 	f(ctx, msg, keyvals...)
 }
+
+type multiLogger []Logger
+
+// MultiLogger returns a logger that passes every log record to all of the passed loggers
+func MultiLogger(loggers ...Logger) Logger {
+	l := make(multiLogger, 0, len(loggers))
+	for _, logger := range loggers {
+		if logger != nil {
+			l = append(l, logger)
+		}
+	}
+
+	return l
+}
+
+func (l multiLogger) Log(ctx context.Context, msg string, keyvals ...interface{}) {
+	for _, logger := range l {
+		logger.Log(ctx, msg, keyvals...)
+	}
+}
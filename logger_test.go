@@ -0,0 +1,21 @@
+package instrumentedsql_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luna-duclos/instrumentedsql"
+	"github.com/luna-duclos/instrumentedsql/instrumentedsqltest"
+)
+
+func TestMultiLogger(t *testing.T) {
+	first := instrumentedsqltest.NewRecordingLogger()
+	second := instrumentedsqltest.NewRecordingLogger()
+	logger := instrumentedsql.MultiLogger(first, nil, second)
+
+	logger.Log(context.Background(), "msg", "key", "value")
+
+	for _, l := range []*instrumentedsqltest.RecordingLogger{first, second} {
+		l.AssertLoggedValue(t, "msg", "key", "value")
+	}
+}
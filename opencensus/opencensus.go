@@ -3,12 +3,24 @@ package opencensus
 import (
 	"context"
 	"database/sql/driver"
+	"unicode/utf8"
 
 	"go.opencensus.io/trace"
 
 	"github.com/luna-duclos/instrumentedsql"
 )
 
+const (
+	labelQuery    = "query"
+	labelArgs     = "args"
+	labelErrClass = "err_class"
+
+	// maxAnnotationLength is the longest annotation description exporters such as Stackdriver accept, longer ones are truncated
+	maxAnnotationLength = 256
+	// maxAttributeLength bounds the query and args attributes, so that huge inlined values don't bloat every exported span
+	maxAttributeLength = 16 * 1024
+)
+
 type tracer struct {
 	traceOrphans bool
 }
@@ -16,6 +28,7 @@ type tracer struct {
 type span struct {
 	tracer
 	parent *trace.Span
+	err    error
 }
 
 // NewTracer returns a tracer that will fetch spans using opencensus's FromContext function
@@ -25,13 +38,24 @@ func NewTracer(traceOrphans bool) instrumentedsql.Tracer { return tracer{traceOr
 // GetSpan fetches a span from the context and wraps it
 func (t tracer) GetSpan(ctx context.Context) instrumentedsql.Span {
 	if ctx == nil {
-		return span{parent: nil, tracer: t}
+		return &span{parent: nil, tracer: t}
 	}
 
-	return span{parent: trace.FromContext(ctx), tracer: t}
+	return &span{parent: trace.FromContext(ctx), tracer: t}
+}
+
+// HasSpan reports whether opencensus's FromContext finds a span in ctx
+func (t tracer) HasSpan(ctx context.Context) bool {
+	return ctx != nil && trace.FromContext(ctx) != nil
+}
+
+// NewRootSpan starts a new root span
+func (t tracer) NewRootSpan(ctx context.Context, name string) instrumentedsql.Span {
+	_, parent := trace.StartSpan(context.Background(), name)
+	return &span{parent: parent, tracer: t}
 }
 
-func (s span) NewChild(name string) instrumentedsql.Span {
+func (s *span) NewChild(name string) instrumentedsql.Span {
 	if s.parent == nil && !s.traceOrphans {
 		return s
 	}
@@ -44,21 +68,78 @@ func (s span) NewChild(name string) instrumentedsql.Span {
 		_, parent = trace.StartSpan(trace.NewContext(context.Background(), s.parent), name)
 	}
 
-	return span{parent: parent, tracer: s.tracer}
+	return &span{parent: parent, tracer: s.tracer}
 }
 
-func (s span) SetLabel(k, v string) {
+func (s *span) SetLabel(k, v string) {
+	switch k {
+	case labelQuery, labelArgs:
+		// Exporters that drop long attributes still show the annotation
+		s.parent.AddAttributes(trace.StringAttribute(k, truncate(v, maxAttributeLength)))
+		s.parent.Annotate([]trace.Attribute{trace.StringAttribute("label", k)}, truncate(v, maxAnnotationLength))
+		return
+	}
+
 	s.parent.AddAttributes(trace.StringAttribute(k, v))
+
+	switch k {
+	case labelErrClass:
+		// SetError could only map the error itself, a driver error can still stem from a cancelled or expired context
+		if s.err != nil {
+			s.parent.SetStatus(trace.Status{Code: errClassStatusCode(v), Message: s.err.Error()})
+		}
+	}
 }
 
-func (s span) SetError(err error) {
+func (s *span) SetError(err error) {
 	if err == nil || err == driver.ErrSkip {
 		return
 	}
 
+	s.err = err
 	s.parent.AddAttributes(trace.StringAttribute("err", err.Error()))
+	s.parent.SetStatus(trace.Status{Code: errStatusCode(err), Message: err.Error()})
 }
 
-func (s span) Finish() {
+func (s *span) Finish() {
 	s.parent.End()
 }
+
+// errStatusCode maps err to a canonical status code
+func errStatusCode(err error) int32 {
+	switch err {
+	case context.Canceled:
+		return trace.StatusCodeCancelled
+	case context.DeadlineExceeded:
+		return trace.StatusCodeDeadlineExceeded
+	default:
+		return trace.StatusCodeUnknown
+	}
+}
+
+// errClassStatusCode maps the err_class label set by instrumentedsql to a canonical status code
+func errClassStatusCode(class string) int32 {
+	switch class {
+	case "cancelled":
+		return trace.StatusCodeCancelled
+	case "deadline_exceeded":
+		return trace.StatusCodeDeadlineExceeded
+	default:
+		return trace.StatusCodeUnknown
+	}
+}
+
+// truncate shortens s to at most n bytes without splitting a rune, ending it with an ellipsis
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	const ellipsis = "..."
+	cut := n - len(ellipsis)
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+
+	return s[:cut] + ellipsis
+}
@@ -1,11 +1,17 @@
 package opencensus_test
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/luna-duclos/instrumentedsql"
 	"github.com/luna-duclos/instrumentedsql/opencensus"
+	"go.opencensus.io/trace"
 )
 
 // WrapDriverOpencensus demonstrates how to call wrapDriver and register a new driver.
@@ -17,3 +23,71 @@ func ExampleWrapDriver_opencensus() {
 	// Proceed to handle connection errors and use the database as usual
 	_, _ = db, err
 }
+
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []*trace.SpanData
+}
+
+func (e *recordingExporter) ExportSpan(s *trace.SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, s)
+}
+
+func TestSpanStatusAndAnnotations(t *testing.T) {
+	exporter := &recordingExporter{}
+	trace.RegisterExporter(exporter)
+	defer trace.UnregisterExporter(exporter)
+
+	ctx, parent := trace.StartSpan(context.Background(), "parent", trace.WithSampler(trace.AlwaysSample()))
+	defer parent.End()
+
+	tr := opencensus.NewTracer(false)
+	longQuery := "SELECT " + strings.Repeat("a, ", 200) + "b FROM t"
+
+	tests := []struct {
+		name     string
+		err      error
+		errClass string
+		want     int32
+	}{
+		{name: "success", want: trace.StatusCodeOK},
+		{name: "context cancelled", err: context.Canceled, errClass: "cancelled", want: trace.StatusCodeCancelled},
+		{name: "driver error on expired context", err: errors.New("i/o timeout"), errClass: "deadline_exceeded", want: trace.StatusCodeDeadlineExceeded},
+		{name: "server error", err: errors.New("syntax error"), errClass: "server_error", want: trace.StatusCodeUnknown},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exporter.spans = nil
+
+			span := tr.GetSpan(ctx).NewChild(test.name)
+			span.SetLabel("query", longQuery)
+			span.SetError(test.err)
+			if test.errClass != "" {
+				span.SetLabel("err_class", test.errClass)
+			}
+			span.Finish()
+
+			if len(exporter.spans) != 1 {
+				t.Fatalf("expected 1 exported span, got %d", len(exporter.spans))
+			}
+			data := exporter.spans[0]
+			if data.Status.Code != test.want {
+				t.Errorf("expected status code %d, got %d", test.want, data.Status.Code)
+			}
+			if test.err != nil && data.Status.Message != test.err.Error() {
+				t.Errorf("expected status message %q, got %q", test.err.Error(), data.Status.Message)
+			}
+			if len(data.Annotations) != 1 {
+				t.Fatalf("expected the query to be annotated, got %d annotations", len(data.Annotations))
+			}
+			if v, _ := data.Attributes["query"].(string); v != longQuery {
+				t.Errorf("expected the query attribute to be kept, got %q", v)
+			}
+			if msg := data.Annotations[0].Message; len(msg) > 256 || !strings.HasPrefix(longQuery, strings.TrimSuffix(msg, "...")) {
+				t.Errorf("expected the query annotation to be truncated to 256 bytes, got %d bytes: %q", len(msg), msg)
+			}
+		})
+	}
+}
@@ -32,6 +32,16 @@ func (t tracer) GetSpan(ctx context.Context) instrumentedsql.Span {
 	return span{parent: opentracing.SpanFromContext(ctx), tracer: t}
 }
 
+// HasSpan reports whether ctx has an opentracing span attached
+func (t tracer) HasSpan(ctx context.Context) bool {
+	return ctx != nil && opentracing.SpanFromContext(ctx) != nil
+}
+
+// NewRootSpan starts a new root span using the global tracer
+func (t tracer) NewRootSpan(ctx context.Context, name string) instrumentedsql.Span {
+	return span{parent: opentracing.StartSpan(name), tracer: t}
+}
+
 func (s span) NewChild(name string) instrumentedsql.Span {
 	if s.parent == nil {
 		if s.traceOrphans {
@@ -53,3 +53,24 @@ func TestSpanWithoutParent(t *testing.T) {
 
 	span.Finish()
 }
+
+func TestNewRootSpan(t *testing.T) {
+	tr := opentracing.NewTracer(false).(interface {
+		HasSpan(ctx context.Context) bool
+		NewRootSpan(ctx context.Context, name string) instrumentedsql.Span
+	})
+
+	if tr.HasSpan(context.Background()) {
+		t.Error("expected background context to carry no span")
+	}
+
+	ctx := opentracinggo.ContextWithSpan(context.Background(), opentracinggo.GlobalTracer().StartSpan("some_span"))
+	if !tr.HasSpan(ctx) {
+		t.Error("expected context to carry a span")
+	}
+
+	span := tr.NewRootSpan(context.Background(), "root")
+	span.SetLabel("key", "value")
+	span.NewChild("child").Finish()
+	span.Finish()
+}
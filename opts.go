@@ -1,18 +1,148 @@
 package instrumentedsql
 
+import "context"
+
 type opts struct {
 	Logger
 	Tracer
+	// Deprecated: OpsExcluded is still filled in by WithOpsExcluded so that it can be read, but ops are only excluded
+	// through WithOpsExcluded, changing it has no effect.
 	OpsExcluded map[string]struct{}
+	opsExcluded opMask
 	OmitArgs    bool
+	SanitizeArg ArgSanitizer
+
+	NewRootSpanIfMissing bool
+
+	AuditSink     AuditSink
+	AuditIdentity AuditIdentityFunc
+
+	badConns *badConnTracker
 }
 
+// newOpts returns the options a driver starts out with, the options it wraps things with are shared by all of them
+func newOpts() *opts {
+	return &opts{badConns: &badConnTracker{}}
+}
+
+// ArgSanitizer is called for every query argument before it is logged or traced and returns the value to report instead.
+// name is empty for positional arguments, ordinal starts at 1.
+type ArgSanitizer func(name string, ordinal int, value interface{}) interface{}
+
 // Opt is a functional option type for the wrapped driver
 type Opt func(*opts)
 
-func (o *opts) hasOpExcluded(op string) bool {
-	_, ok := o.OpsExcluded[op]
-	return ok
+// opMask holds one bit per op, so that checking whether ops are excluded does not take a map lookup
+type opMask uint32
+
+const (
+	opPrepare opMask = 1 << iota
+	opConnExec
+	opConnQuery
+	opStmtExec
+	opStmtQuery
+	opStmtClose
+	opTxBegin
+	opTxCommit
+	opTxRollback
+	opResLastInsertID
+	opResRowsAffected
+	opRowsNext
+	opPing
+	opDummyPing
+	opConnectorConnect
+	opBadConn
+
+	// The ops instrumented by each wrapper, including the ones of the wrappers it returns
+	opResult = opResLastInsertID | opResRowsAffected
+	opTx     = opTxCommit | opTxRollback
+	opStmt   = opStmtClose | opStmtExec | opStmtQuery | opBadConn | opRowsNext | opResult
+	opConn   = opPrepare | opConnExec | opConnQuery | opTxBegin | opPing | opDummyPing | opBadConn | opStmt | opTx
+)
+
+var opMasks = map[string]opMask{
+	OpSQLPrepare:          opPrepare,
+	OpSQLConnExec:         opConnExec,
+	OpSQLConnQuery:        opConnQuery,
+	OpSQLStmtExec:         opStmtExec,
+	OpSQLStmtQuery:        opStmtQuery,
+	OpSQLStmtClose:        opStmtClose,
+	OpSQLTxBegin:          opTxBegin,
+	OpSQLTxCommit:         opTxCommit,
+	OpSQLTxRollback:       opTxRollback,
+	OpSQLResLastInsertID:  opResLastInsertID,
+	OpSQLResRowsAffected:  opResRowsAffected,
+	OpSQLRowsNext:         opRowsNext,
+	OpSQLPing:             opPing,
+	OpSQLDummyPing:        opDummyPing,
+	OpSQLConnectorConnect: opConnectorConnect,
+	OpSQLBadConn:          opBadConn,
+}
+
+// hasOpExcluded reports whether all of the ops in mask are excluded
+func (o *opts) hasOpExcluded(mask opMask) bool {
+	return o.opsExcluded&mask == mask
+}
+
+// rootSpanContext is a context carrying a root span started by WithNewRootSpanIfMissing,
+// the ops run with it become children of that root span instead of starting their own
+type rootSpanContext struct {
+	context.Context
+	root Span
+	// started is set in the context of the op that started root. That op ends it, unless it hands it over to the rows
+	// or transaction it returns, which end it when they are closed, committed or rolled back.
+	started bool
+}
+
+// rootSpan returns the root span carried by ctx, if any
+func rootSpan(ctx context.Context) Span {
+	if rc, ok := ctx.(*rootSpanContext); ok {
+		return rc.root
+	}
+
+	return nil
+}
+
+// startedRoot reports whether ctx carries a root span started by the op it was returned to
+func startedRoot(ctx context.Context) bool {
+	rc, ok := ctx.(*rootSpanContext)
+	return ok && rc.started
+}
+
+// callerContext returns ctx without the root span it may carry, for the wrappers that can outlive that root span
+func callerContext(ctx context.Context) context.Context {
+	if rc, ok := ctx.(*rootSpanContext); ok {
+		return rc.Context
+	}
+
+	return ctx
+}
+
+// startSpan starts the span for an op called with the context of its caller, as a child of the span carried by ctx.
+// If ctx carries none and WithNewRootSpanIfMissing was used, it is a child of root if set, or a new root span otherwise,
+// and the returned context carries that root span so that the wrappers returned by the op can keep it.
+func (o *opts) startSpan(ctx context.Context, root Span, op string) (Span, context.Context) {
+	if o.NewRootSpanIfMissing && ctx != nil {
+		if rt, ok := o.Tracer.(RootSpanTracer); ok && !rt.HasSpan(ctx) {
+			if root == nil {
+				root = rt.NewRootSpan(ctx, op)
+				return root, &rootSpanContext{Context: ctx, root: root, started: true}
+			}
+			return root.NewChild(op), &rootSpanContext{Context: ctx, root: root}
+		}
+	}
+
+	return o.GetSpan(ctx).NewChild(op), ctx
+}
+
+// newSpan starts the span for an op of a wrapper returned by another op, ctx being the context the wrapper kept.
+// It is a child of the root span ctx carries if one was started, and of the span ctx carries otherwise.
+func (o *opts) newSpan(ctx context.Context, op string) Span {
+	if root := rootSpan(ctx); root != nil {
+		return root.NewChild(op)
+	}
+
+	return o.GetSpan(ctx).NewChild(op)
 }
 
 // WithLogger sets the logger of the wrapped driver to the provided logger
@@ -22,12 +152,20 @@ func WithLogger(l Logger) Opt {
 	}
 }
 
-// WithOpsExcluded excludes some of OpSQL that are not required
+// WithOpsExcluded excludes some of OpSQL that are not required.
+// Connections, statements, transactions, rows and results left with nothing to instrument are not wrapped at all,
+// so excluding every op makes the wrapped driver as cheap as the parent one.
+//
+// Bad connections are counted and logged as OpSQLBadConn whatever other ops are excluded, which keeps connections
+// wrapped. Configurations that excluded every op before OpSQLBadConn was added must now exclude it as well to leave
+// connections unwrapped and stop the new log records.
 func WithOpsExcluded(ops ...string) Opt {
 	return func(o *opts) {
 		o.OpsExcluded = make(map[string]struct{})
+		o.opsExcluded = 0
 		for _, op := range ops {
 			o.OpsExcluded[op] = struct{}{}
+			o.opsExcluded |= opMasks[op]
 		}
 	}
 }
@@ -53,3 +191,41 @@ func WithIncludeArgs() Opt {
 		o.OmitArgs = false
 	}
 }
+
+// WithArgSanitizer sets a function through which every query argument is passed before it is logged or traced,
+// allowing sensitive values to be redacted by parameter name or position
+func WithArgSanitizer(s ArgSanitizer) Opt {
+	return func(o *opts) {
+		o.SanitizeArg = s
+	}
+}
+
+// WithNewRootSpanIfMissing will make it so that a new root span is started for operations whose context carries no span,
+// instead of a child of nothing. This makes SQL issued by background jobs visible in tracing backends.
+// The root span of a query stays open until its rows are closed and the ops of the rows become its children,
+// the root span of a transaction stays open until it is committed or rolled back and the ops run as part of it become
+// its children. Each execution of a prepared statement starts a root span of its own, and the ops of results are never
+// part of one. Operations run without a context never start a root span.
+// It requires the tracer to implement RootSpanTracer, it has no effect otherwise.
+func WithNewRootSpanIfMissing() Opt {
+	return func(o *opts) {
+		o.NewRootSpanIfMissing = true
+	}
+}
+
+// WithAuditSink enables audit mode: every INSERT, UPDATE, DELETE, REPLACE and MERGE statement is reported to the sink,
+// independently of logging, tracing and the ops excluded from them.
+// Statements are recognized by their keywords rather than parsed, data modified through CALL, SELECT INTO, TRUNCATE
+// and other DDL, or functions with side effects, is not reported.
+func WithAuditSink(s AuditSink) Opt {
+	return func(o *opts) {
+		o.AuditSink = s
+	}
+}
+
+// WithAuditIdentity sets the function used to fill in the user and tenant of audit records
+func WithAuditIdentity(f AuditIdentityFunc) Opt {
+	return func(o *opts) {
+		o.AuditIdentity = f
+	}
+}
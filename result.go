@@ -7,35 +7,46 @@ import (
 )
 
 type wrappedResult struct {
-	opts
+	*opts
 	ctx    context.Context
 	parent driver.Result
 }
 
-func (r wrappedResult) LastInsertId() (id int64, err error) {
-	if !r.hasOpExcluded(OpSQLResLastInsertID) {
-		span := r.GetSpan(r.ctx).NewChild(OpSQLResLastInsertID)
+// wrapResult wraps res, unless there is nothing to instrument about it
+func (o *opts) wrapResult(ctx context.Context, res driver.Result) driver.Result {
+	if o.hasOpExcluded(opResult) {
+		return res
+	}
+
+	return &wrappedResult{opts: o, ctx: ctx, parent: res}
+}
+
+func (r *wrappedResult) LastInsertId() (id int64, err error) {
+	if !r.hasOpExcluded(opResLastInsertID) {
+		span := r.newSpan(r.ctx, OpSQLResLastInsertID)
 		span.SetLabel("component", "database/sql")
+		setDeadlineLabel(r.ctx, span)
 		start := time.Now()
 		defer func() {
-			span.SetError(err)
+			setSpanError(r.ctx, span, err)
 			span.Finish()
-			r.Log(r.ctx, OpSQLResLastInsertID, "err", err, "duration", time.Since(start))
+			logOp(r.ctx, r.opts, OpSQLResLastInsertID, err, start)
 		}()
 	}
 
 	return r.parent.LastInsertId()
 }
 
-func (r wrappedResult) RowsAffected() (num int64, err error) {
-	if !r.hasOpExcluded(OpSQLResRowsAffected) {
-		span := r.GetSpan(r.ctx).NewChild(OpSQLResRowsAffected)
+func (r *wrappedResult) RowsAffected() (num int64, err error) {
+	if !r.hasOpExcluded(opResRowsAffected) {
+		span := r.newSpan(r.ctx, OpSQLResRowsAffected)
 		span.SetLabel("component", "database/sql")
+		setDeadlineLabel(r.ctx, span)
 		start := time.Now()
 		defer func() {
-			span.SetError(err)
+			setSpanError(r.ctx, span, err)
 			span.Finish()
-			r.Log(r.ctx, OpSQLResRowsAffected, "err", err, "duration", time.Since(start))
+			logOp(r.ctx, r.opts, OpSQLResRowsAffected, err, start)
 		}()
 	}
 
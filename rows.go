@@ -9,7 +9,7 @@ import (
 
 // Compile time validation that our types implement the expected interfaces
 var (
-	_ driver.Rows                           = wrappedRows{}
+	_ driver.Rows                           = &wrappedRows{}
 	_ driver.RowsColumnTypeDatabaseTypeName // TODO
 	_ driver.RowsColumnTypeLength           // TODO
 	_ driver.RowsColumnTypeNullable         // TODO
@@ -19,33 +19,58 @@ var (
 )
 
 type wrappedRows struct {
-	opts
+	*opts
 	ctx    context.Context
 	parent driver.Rows
+	// root is the root span started for the query that returned the rows, it ends when they are closed
+	root Span
 }
 
-func (r wrappedRows) Columns() []string {
+// wrapRows wraps rows, unless there is nothing to instrument about them nor a root span to end with them
+func (o *opts) wrapRows(ctx context.Context, rows driver.Rows) driver.Rows {
+	var root Span
+	if startedRoot(ctx) {
+		root = rootSpan(ctx)
+	}
+	if o.hasOpExcluded(opRowsNext) && root == nil {
+		return rows
+	}
+
+	return &wrappedRows{opts: o, ctx: ctx, parent: rows, root: root}
+}
+
+func (r *wrappedRows) Columns() []string {
 	return r.parent.Columns()
 }
 
-func (r wrappedRows) Close() error {
+func (r *wrappedRows) Close() error {
+	if r.root != nil {
+		defer r.root.Finish()
+	}
+
 	return r.parent.Close()
 }
 
-func (r wrappedRows) Next(dest []driver.Value) (err error) {
-	if !r.hasOpExcluded(OpSQLRowsNext) {
-		span := r.GetSpan(r.ctx).NewChild(OpSQLRowsNext)
+func (r *wrappedRows) Next(dest []driver.Value) (err error) {
+	if !r.hasOpExcluded(opRowsNext) {
+		span := r.newSpan(r.ctx, OpSQLRowsNext)
 		span.SetLabel("component", "database/sql")
+		setDeadlineLabel(r.ctx, span)
 		defer func() {
 			if err != io.EOF {
-				span.SetError(err)
+				setSpanError(r.ctx, span, err)
 			}
 			span.Finish()
 		}()
 
 		start := time.Now()
 		defer func() {
-			r.Log(r.ctx, OpSQLRowsNext, "err", err, "duration", time.Since(start))
+			// io.EOF only signals the end of the rows
+			if err == io.EOF {
+				r.Log(r.ctx, OpSQLRowsNext, "err", err, "duration", time.Since(start))
+				return
+			}
+			logOp(r.ctx, r.opts, OpSQLRowsNext, err, start)
 		}()
 	}
 
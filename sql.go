@@ -18,3 +18,14 @@ const (
 	OpSQLDummyPing        = "sql-dummy-ping"
 	OpSQLConnectorConnect = "sql-connector-connect"
 )
+
+// OpSQLBadConn is the message logged when an op fails with driver.ErrBadConn, it is not a span of its own.
+// Excluding it stops bad connections from being logged and counted, and from being spotted on the spans of retries.
+const OpSQLBadConn = "sql-bad-conn"
+
+// The possible values of the err_class label set on spans of failed operations
+const (
+	ErrClassCancelled        = "cancelled"
+	ErrClassDeadlineExceeded = "deadline_exceeded"
+	ErrClassServerError      = "server_error"
+)
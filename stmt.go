@@ -7,109 +7,168 @@ import (
 )
 
 type wrappedStmt struct {
-	opts
+	*opts
 	ctx    context.Context
 	query  string
 	parent driver.Stmt
+	txRoot *txRoot
 }
 
 // Compile time validation that our types implement the expected interfaces
 var (
-	_ driver.Stmt             = wrappedStmt{}
-	_ driver.StmtExecContext  = wrappedStmt{}
-	_ driver.StmtQueryContext = wrappedStmt{}
+	_ driver.Stmt             = &wrappedStmt{}
+	_ driver.StmtExecContext  = &wrappedStmt{}
+	_ driver.StmtQueryContext = &wrappedStmt{}
 )
 
-func (s wrappedStmt) Close() (err error) {
-	if !s.hasOpExcluded(OpSQLStmtClose) {
-		span := s.GetSpan(s.ctx).NewChild(OpSQLStmtClose)
+// wrapStmt wraps stmt, unless there is nothing to instrument or audit about it or the rows and results it returns
+// The executions of stmt while a transaction is in progress on its connection become children of the root span of the
+// transaction, if one was started.
+func (o *opts) wrapStmt(ctx context.Context, query string, stmt driver.Stmt, txRoot *txRoot) driver.Stmt {
+	if o.hasOpExcluded(opStmt) && o.auditVerb(query) == "" {
+		return stmt
+	}
+
+	return &wrappedStmt{opts: o, ctx: ctx, query: query, parent: stmt, txRoot: txRoot}
+}
+
+func (s *wrappedStmt) Close() (err error) {
+	if !s.hasOpExcluded(opStmtClose) {
+		span := s.newSpan(s.ctx, OpSQLStmtClose)
 		span.SetLabel("component", "database/sql")
+		setDeadlineLabel(s.ctx, span)
 		start := time.Now()
 		defer func() {
-			span.SetError(err)
+			setSpanError(s.ctx, span, err)
 			span.Finish()
-			s.Log(s.ctx, OpSQLStmtClose, "err", err, "duration", time.Since(start))
+			logOp(s.ctx, s.opts, OpSQLStmtClose, err, start)
 		}()
 	}
 
 	return s.parent.Close()
 }
 
-func (s wrappedStmt) NumInput() int {
+func (s *wrappedStmt) NumInput() int {
 	return s.parent.NumInput()
 }
 
-func (s wrappedStmt) Exec(args []driver.Value) (res driver.Result, err error) {
-	if !s.hasOpExcluded(OpSQLStmtExec) {
-		span := s.GetSpan(s.ctx).NewChild(OpSQLStmtExec)
+func (s *wrappedStmt) Exec(args []driver.Value) (res driver.Result, err error) {
+	if !s.hasOpExcluded(opStmtExec) {
+		span := s.newSpan(s.ctx, OpSQLStmtExec)
 		span.SetLabel("component", "database/sql")
+		setDeadlineLabel(s.ctx, span)
+		s.setRetryLabel(s.ctx, OpSQLStmtExec, s.query, span)
 		span.SetLabel("query", s.query)
 		if !s.OmitArgs {
-			span.SetLabel("args", formatArgs(args))
+			span.SetLabel("args", formatArgs(args, s.SanitizeArg))
 		}
 		start := time.Now()
 		defer func() {
-			span.SetError(err)
+			setSpanError(s.ctx, span, err)
 			span.Finish()
 			logQuery(s.ctx, s.opts, OpSQLStmtExec, s.query, err, args, start)
 		}()
 	}
 
+	if !s.hasOpExcluded(opBadConn) {
+		defer func() {
+			s.reportBadConn(s.ctx, OpSQLStmtExec, s.query, err)
+		}()
+	}
+
+	if verb := s.auditVerb(s.query); verb != "" {
+		start := time.Now()
+		defer func() {
+			s.audit(s.ctx, verb, s.query, args, res, err, start)
+		}()
+	}
+
 	res, err = s.parent.Exec(args)
 	if err != nil {
 		return nil, err
 	}
 
-	return wrappedResult{opts: s.opts, ctx: s.ctx, parent: res}, nil
+	return s.wrapResult(s.ctx, res), nil
 }
 
-func (s wrappedStmt) Query(args []driver.Value) (rows driver.Rows, err error) {
-	if !s.hasOpExcluded(OpSQLStmtQuery) {
-		span := s.GetSpan(s.ctx).NewChild(OpSQLStmtQuery)
+func (s *wrappedStmt) Query(args []driver.Value) (rows driver.Rows, err error) {
+	if !s.hasOpExcluded(opStmtQuery) {
+		span := s.newSpan(s.ctx, OpSQLStmtQuery)
 		span.SetLabel("component", "database/sql")
+		setDeadlineLabel(s.ctx, span)
+		s.setRetryLabel(s.ctx, OpSQLStmtQuery, s.query, span)
 		span.SetLabel("query", s.query)
 		if !s.OmitArgs {
-			span.SetLabel("args", formatArgs(args))
+			span.SetLabel("args", formatArgs(args, s.SanitizeArg))
 		}
 		start := time.Now()
 		defer func() {
-			span.SetError(err)
+			setSpanError(s.ctx, span, err)
 			span.Finish()
 			logQuery(s.ctx, s.opts, OpSQLStmtQuery, s.query, err, args, start)
 		}()
 	}
 
+	if !s.hasOpExcluded(opBadConn) {
+		defer func() {
+			s.reportBadConn(s.ctx, OpSQLStmtQuery, s.query, err)
+		}()
+	}
+
+	if verb := s.auditVerb(s.query); verb != "" {
+		start := time.Now()
+		defer func() {
+			s.audit(s.ctx, verb, s.query, args, nil, err, start)
+		}()
+	}
+
 	rows, err = s.parent.Query(args)
 	if err != nil {
 		return nil, err
 	}
 
-	return wrappedRows{opts: s.opts, ctx: s.ctx, parent: rows}, nil
+	return s.wrapRows(s.ctx, rows), nil
 }
 
-func (s wrappedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (res driver.Result, err error) {
-	if !s.hasOpExcluded(OpSQLStmtExec) {
-		span := s.GetSpan(ctx).NewChild(OpSQLStmtExec)
+func (s *wrappedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (res driver.Result, err error) {
+	if !s.hasOpExcluded(opStmtExec) {
+		var span Span
+		span, ctx = s.startSpan(ctx, s.txRoot.get(), OpSQLStmtExec)
 		span.SetLabel("component", "database/sql")
+		setDeadlineLabel(ctx, span)
+		s.setRetryLabel(ctx, OpSQLStmtExec, s.query, span)
 		span.SetLabel("query", s.query)
 		if !s.OmitArgs {
-			span.SetLabel("args", formatArgs(args))
+			span.SetLabel("args", formatArgs(args, s.SanitizeArg))
 		}
 		start := time.Now()
 		defer func() {
-			span.SetError(err)
+			setSpanError(ctx, span, err)
 			span.Finish()
 			logQuery(ctx, s.opts, OpSQLStmtExec, s.query, err, args, start)
 		}()
 	}
 
+	if !s.hasOpExcluded(opBadConn) {
+		defer func() {
+			s.reportBadConn(ctx, OpSQLStmtExec, s.query, err)
+		}()
+	}
+
+	if verb := s.auditVerb(s.query); verb != "" {
+		start := time.Now()
+		defer func() {
+			s.audit(ctx, verb, s.query, args, res, err, start)
+		}()
+	}
+
 	if stmtExecContext, ok := s.parent.(driver.StmtExecContext); ok {
 		res, err := stmtExecContext.ExecContext(ctx, args)
 		if err != nil {
 			return nil, err
 		}
 
-		return wrappedResult{opts: s.opts, ctx: ctx, parent: res}, nil
+		return s.wrapResult(callerContext(ctx), res), nil
 	}
 
 	// Fallback implementation
@@ -129,32 +188,51 @@ func (s wrappedStmt) ExecContext(ctx context.Context, args []driver.NamedValue)
 		return nil, err
 	}
 
-	return wrappedResult{opts: s.opts, ctx: ctx, parent: res}, nil
+	return s.wrapResult(callerContext(ctx), res), nil
 }
 
-func (s wrappedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (rows driver.Rows, err error) {
-	if !s.hasOpExcluded(OpSQLStmtQuery) {
-		span := s.GetSpan(ctx).NewChild(OpSQLStmtQuery)
+func (s *wrappedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (rows driver.Rows, err error) {
+	if !s.hasOpExcluded(opStmtQuery) {
+		var span Span
+		span, ctx = s.startSpan(ctx, s.txRoot.get(), OpSQLStmtQuery)
 		span.SetLabel("component", "database/sql")
+		setDeadlineLabel(ctx, span)
+		s.setRetryLabel(ctx, OpSQLStmtQuery, s.query, span)
 		span.SetLabel("query", s.query)
 		if !s.OmitArgs {
-			span.SetLabel("args", formatArgs(args))
+			span.SetLabel("args", formatArgs(args, s.SanitizeArg))
 		}
 		start := time.Now()
 		defer func() {
-			span.SetError(err)
-			span.Finish()
+			setSpanError(ctx, span, err)
+			// The root span started for the query ends with its rows
+			if err != nil || !startedRoot(ctx) {
+				span.Finish()
+			}
 			logQuery(ctx, s.opts, OpSQLStmtQuery, s.query, err, args, start)
 		}()
 	}
 
+	if !s.hasOpExcluded(opBadConn) {
+		defer func() {
+			s.reportBadConn(ctx, OpSQLStmtQuery, s.query, err)
+		}()
+	}
+
+	if verb := s.auditVerb(s.query); verb != "" {
+		start := time.Now()
+		defer func() {
+			s.audit(ctx, verb, s.query, args, nil, err, start)
+		}()
+	}
+
 	if stmtQueryContext, ok := s.parent.(driver.StmtQueryContext); ok {
 		rows, err := stmtQueryContext.QueryContext(ctx, args)
 		if err != nil {
 			return nil, err
 		}
 
-		return wrappedRows{opts: s.opts, ctx: ctx, parent: rows}, nil
+		return s.wrapRows(ctx, rows), nil
 	}
 
 	dargs, err := namedValueToValue(args)
@@ -173,5 +251,5 @@ func (s wrappedStmt) QueryContext(ctx context.Context, args []driver.NamedValue)
 		return nil, err
 	}
 
-	return wrappedRows{opts: s.opts, ctx: ctx, parent: rows}, nil
+	return s.wrapRows(ctx, rows), nil
 }
@@ -12,9 +12,9 @@ import (
 	"reflect"
 )
 
-var _ driver.NamedValueChecker = wrappedStmt{}
+var _ driver.NamedValueChecker = &wrappedStmt{}
 
-func (s wrappedStmt) CheckNamedValue(v *driver.NamedValue) error {
+func (s *wrappedStmt) CheckNamedValue(v *driver.NamedValue) error {
 	if checker, ok := s.parent.(driver.NamedValueChecker); ok {
 		err := checker.CheckNamedValue(v)
 		if err != driver.ErrSkip {
@@ -15,6 +15,15 @@ type Span interface {
 	Finish()
 }
 
+// RootSpanTracer is an optional interface a Tracer can implement to support WithNewRootSpanIfMissing
+type RootSpanTracer interface {
+	Tracer
+	// HasSpan reports whether ctx carries a span
+	HasSpan(ctx context.Context) bool
+	// NewRootSpan starts a new root span with the given name
+	NewRootSpan(ctx context.Context, name string) Span
+}
+
 type nullTracer struct{}
 type nullSpan struct{}
 
@@ -31,3 +40,83 @@ func (nullSpan) SetLabel(k, v string) {}
 func (nullSpan) Finish() {}
 
 func (nullSpan) SetError(err error) {}
+
+type multiTracer []Tracer
+type multiSpan []Span
+
+var _ RootSpanTracer = multiTracer{}
+
+// MultiTracer returns a tracer that fans out every span to all of the passed tracers,
+// which allows emitting to several tracing backends at once, for example while migrating from one to another
+func MultiTracer(tracers ...Tracer) Tracer {
+	t := make(multiTracer, 0, len(tracers))
+	for _, tracer := range tracers {
+		if tracer != nil {
+			t = append(t, tracer)
+		}
+	}
+
+	return t
+}
+
+func (t multiTracer) GetSpan(ctx context.Context) Span {
+	spans := make(multiSpan, len(t))
+	for i, tracer := range t {
+		spans[i] = tracer.GetSpan(ctx)
+	}
+
+	return spans
+}
+
+// HasSpan reports whether every tracer able to start root spans finds a span in ctx
+func (t multiTracer) HasSpan(ctx context.Context) bool {
+	for _, tracer := range t {
+		if rt, ok := tracer.(RootSpanTracer); ok && !rt.HasSpan(ctx) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewRootSpan starts a root span in every tracer that is able to and finds no span in ctx,
+// the other tracers start a child span as usual
+func (t multiTracer) NewRootSpan(ctx context.Context, name string) Span {
+	spans := make(multiSpan, len(t))
+	for i, tracer := range t {
+		if rt, ok := tracer.(RootSpanTracer); ok && !rt.HasSpan(ctx) {
+			spans[i] = rt.NewRootSpan(ctx, name)
+		} else {
+			spans[i] = tracer.GetSpan(ctx).NewChild(name)
+		}
+	}
+
+	return spans
+}
+
+func (s multiSpan) NewChild(name string) Span {
+	children := make(multiSpan, len(s))
+	for i, span := range s {
+		children[i] = span.NewChild(name)
+	}
+
+	return children
+}
+
+func (s multiSpan) SetLabel(k, v string) {
+	for _, span := range s {
+		span.SetLabel(k, v)
+	}
+}
+
+func (s multiSpan) SetError(err error) {
+	for _, span := range s {
+		span.SetError(err)
+	}
+}
+
+func (s multiSpan) Finish() {
+	for _, span := range s {
+		span.Finish()
+	}
+}
@@ -0,0 +1,27 @@
+package instrumentedsql_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/luna-duclos/instrumentedsql"
+	"github.com/luna-duclos/instrumentedsql/instrumentedsqltest"
+)
+
+func TestMultiTracer(t *testing.T) {
+	first := instrumentedsqltest.NewRecordingTracer()
+	second := instrumentedsqltest.NewRecordingTracer()
+	tracer := instrumentedsql.MultiTracer(first, nil, second)
+
+	err := errors.New("some error")
+	span := tracer.GetSpan(context.Background()).NewChild("child")
+	span.SetLabel("key", "value")
+	span.SetError(err)
+	span.Finish()
+
+	for _, tr := range []*instrumentedsqltest.RecordingTracer{first, second} {
+		tr.AssertSpanLabel(t, "child", "key", "value")
+		tr.AssertSpanError(t, "child", err)
+	}
+}
@@ -7,40 +7,84 @@ import (
 )
 
 type wrappedTx struct {
-	opts
+	*opts
 	ctx    context.Context
 	parent driver.Tx
+	root   *txRoot
+}
+
+// txRoot holds the root span started by WithNewRootSpanIfMissing for the transaction in progress on a connection,
+// the ops run on the connection until the transaction ends become its children, and it ends with the transaction
+type txRoot struct {
+	span Span
+}
+
+func (r *txRoot) get() Span {
+	if r == nil {
+		return nil
+	}
+
+	return r.span
+}
+
+func (r *txRoot) set(span Span) {
+	if r != nil {
+		r.span = span
+	}
+}
+
+// end finishes the root span of the transaction once it is committed or rolled back
+func (r *txRoot) end() {
+	if r != nil && r.span != nil {
+		r.span.Finish()
+		r.span = nil
+	}
 }
 
 // Compile time validation that our types implement the expected interfaces
 var (
-	_ driver.Tx = wrappedTx{}
+	_ driver.Tx = &wrappedTx{}
 )
 
-func (t wrappedTx) Commit() (err error) {
-	if !t.hasOpExcluded(OpSQLTxCommit) {
-		span := t.GetSpan(t.ctx).NewChild(OpSQLTxCommit)
+// wrapTx wraps tx, unless there is nothing to instrument about it nor a root span to end with it
+func (o *opts) wrapTx(ctx context.Context, tx driver.Tx, root *txRoot) driver.Tx {
+	if o.hasOpExcluded(opTx) && root.get() == nil {
+		return tx
+	}
+
+	return &wrappedTx{opts: o, ctx: ctx, parent: tx, root: root}
+}
+
+func (t *wrappedTx) Commit() (err error) {
+	defer t.root.end()
+
+	if !t.hasOpExcluded(opTxCommit) {
+		span := t.newSpan(t.ctx, OpSQLTxCommit)
 		span.SetLabel("component", "database/sql")
+		setDeadlineLabel(t.ctx, span)
 		start := time.Now()
 		defer func() {
-			span.SetError(err)
+			setSpanError(t.ctx, span, err)
 			span.Finish()
-			t.Log(t.ctx, OpSQLTxCommit, "err", err, "duration", time.Since(start))
+			logOp(t.ctx, t.opts, OpSQLTxCommit, err, start)
 		}()
 	}
 
 	return t.parent.Commit()
 }
 
-func (t wrappedTx) Rollback() (err error) {
-	if !t.hasOpExcluded(OpSQLTxRollback) {
-		span := t.GetSpan(t.ctx).NewChild(OpSQLTxRollback)
+func (t *wrappedTx) Rollback() (err error) {
+	defer t.root.end()
+
+	if !t.hasOpExcluded(opTxRollback) {
+		span := t.newSpan(t.ctx, OpSQLTxRollback)
 		span.SetLabel("component", "database/sql")
+		setDeadlineLabel(t.ctx, span)
 		start := time.Now()
 		defer func() {
-			span.SetError(err)
+			setSpanError(t.ctx, span, err)
 			span.Finish()
-			t.Log(t.ctx, OpSQLTxRollback, "err", err, "duration", time.Since(start))
+			logOp(t.ctx, t.opts, OpSQLTxRollback, err, start)
 		}()
 	}
 
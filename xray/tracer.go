@@ -38,6 +38,17 @@ func (tracer) GetSpan(ctx context.Context) instrumentedsql.Span {
 	return span{ctx: ctx}
 }
 
+// HasSpan reports whether an X-Ray segment was begun in ctx
+func (tracer) HasSpan(ctx context.Context) bool {
+	return ctx != nil && xray.GetSegment(ctx) != nil
+}
+
+// NewRootSpan begins a new segment, subsegments of which are started for its children
+func (tracer) NewRootSpan(ctx context.Context, name string) instrumentedsql.Span {
+	ctx, seg := xray.BeginSegment(ctx, name)
+	return span{ctx: ctx, segment: seg}
+}
+
 // NewChild comply with instrumentedsql.Span
 func (s span) NewChild(name string) instrumentedsql.Span {
 	if s.ctx == nil {